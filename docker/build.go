@@ -0,0 +1,245 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/ory/dockertest/v3/docker/pkg/fileutils"
+)
+
+// LabelBuildHash is the Docker label holding the content hash an image was built from.
+// Built images are reused by later sessions while they exist. They are labeled with the session which built them, so
+// they are removed along with it by Cleanup and GC.
+const LabelBuildHash = "com.beatlabs.bake.build-hash"
+
+// buildHashLength is the number of hex digits of the build hash used as image tag.
+const buildHashLength = 16
+
+// BuildSecret exposes a file to the build as a BuildKit secret, mounted with RUN --mount=type=secret,id=<ID>.
+// Secrets are not part of the build hash.
+type BuildSecret struct {
+	ID string
+	// Src is the path of the file holding the secret.
+	Src string
+}
+
+// buildImage builds the image of a component, unless an image of the same content hash exists already.
+// It reports whether the image was built, as opposed to reused.
+func buildImage(ctx context.Context, client *docker.Client, session *Session, component string, opts *BuildOptions,
+) (ImageRef, bool, error) {
+	hash, err := buildHash(opts)
+	if err != nil {
+		return ImageRef{}, false, fmt.Errorf("hash build context of %s: %w", component, err)
+	}
+	image := ImageRef{Repository: component, Tag: hash[:buildHashLength], Platform: opts.Platform}
+
+	_, err = client.InspectImage(image.String())
+	if err == nil {
+		fmt.Printf("Reusing image %s\n", image)
+		return image, false, nil
+	}
+	if !errors.Is(err, docker.ErrNoSuchImage) {
+		return ImageRef{}, false, fmt.Errorf("inspect image %s: %w", image, err)
+	}
+
+	fmt.Printf("Building image %s\n", image)
+	start := time.Now()
+	labels := resourceLabels(session.id, component)
+	labels[LabelBuildHash] = hash
+	var out bytes.Buffer
+	if len(opts.Secrets) > 0 {
+		err = buildImageWithCLI(ctx, image.String(), labels, opts, &out)
+	} else {
+		err = client.BuildImage(docker.BuildImageOptions{
+			Name:           image.String(),
+			Dockerfile:     opts.Dockerfile,
+			OutputStream:   &out,
+			ErrorStream:    &out,
+			ContextDir:     opts.ContextDir,
+			BuildArgs:      opts.BuildArgs,
+			Target:         opts.Target,
+			Platform:       opts.Platform,
+			RmTmpContainer: true,
+			Labels:         labels,
+			Context:        ctx,
+		})
+	}
+	if err != nil {
+		fmt.Printf("=== Build output of image %s\n%s\n===\n", image, strings.TrimRight(out.String(), "\n"))
+		return ImageRef{}, false, fmt.Errorf("build image %s: %w", image, err)
+	}
+	fmt.Printf("Built image %s in %s\n", image, time.Since(start).Round(time.Millisecond))
+	return image, true, nil
+}
+
+// buildImageWithCLI builds an image with the docker CLI and BuildKit, as the build API does not support secrets.
+func buildImageWithCLI(ctx context.Context, name string, labels map[string]string, opts *BuildOptions,
+	out io.Writer,
+) error {
+	args := []string{"build", "--tag", name, "--file", filepath.Join(opts.ContextDir, dockerfile(opts))}
+	for k, v := range labels {
+		args = append(args, "--label", k+"="+v)
+	}
+	for _, arg := range opts.BuildArgs {
+		args = append(args, "--build-arg", arg.Name+"="+arg.Value)
+	}
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	if opts.Platform != "" {
+		args = append(args, "--platform", opts.Platform)
+	}
+	for _, s := range opts.Secrets {
+		args = append(args, "--secret", "id="+s.ID+",src="+s.Src)
+	}
+	args = append(args, opts.ContextDir)
+
+	cmd := exec.CommandContext(ctx, "docker", args...) // #nosec G204 -- the arguments are not passed through a shell.
+	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// buildHash hashes the inputs of a build: the files of the context dir not excluded by its .dockerignore,
+// the Dockerfile, the build args, the target and the platform.
+func buildHash(opts *BuildOptions) (string, error) {
+	h := sha256.New()
+	field := func(name, value string) {
+		fmt.Fprintf(h, "%s=%s\x00", name, value)
+	}
+
+	field("dockerfile", dockerfile(opts))
+	field("target", opts.Target)
+	field("platform", opts.Platform)
+	args := make([]string, 0, len(opts.BuildArgs))
+	for _, arg := range opts.BuildArgs {
+		args = append(args, arg.Name+"="+arg.Value)
+	}
+	sort.Strings(args)
+	for _, arg := range args {
+		field("arg", arg)
+	}
+
+	// The Dockerfile is sent along even if it is excluded by .dockerignore.
+	content, err := os.ReadFile(filepath.Join(opts.ContextDir, dockerfile(opts)))
+	if err != nil {
+		return "", err
+	}
+	field("dockerfile-content", string(content))
+
+	if err := hashContextDir(h, opts.ContextDir); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashContextDir hashes the names, types and contents of the files of a build context, honoring its .dockerignore.
+func hashContextDir(w io.Writer, dir string) error {
+	excludes, err := dockerignorePatterns(dir)
+	if err != nil {
+		return err
+	}
+	pm, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return fmt.Errorf("parse .dockerignore: %w", err)
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		excluded, err := pm.Matches(rel)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			// Exclusion patterns may include files of an excluded directory again.
+			if d.IsDir() && !pm.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		fmt.Fprintf(w, "%s\x00%s\x00", filepath.ToSlash(rel), d.Type())
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\x00", target)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\x00", info.Mode().Perm())
+			f, err := os.Open(filepath.Clean(path))
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			if _, err := io.Copy(w, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dockerignorePatterns reads the exclude patterns of the .dockerignore file of a build context, if any.
+func dockerignorePatterns(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		exclusion := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(line, "!")
+		line = filepath.Clean(strings.TrimPrefix(line, "/"))
+		if exclusion {
+			line = "!" + line
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// dockerfile returns the path of the Dockerfile relative to the build context.
+func dockerfile(opts *BuildOptions) string {
+	if opts.Dockerfile == "" {
+		return "Dockerfile"
+	}
+	return opts.Dockerfile
+}
@@ -0,0 +1,98 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBuildContext(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		fpath := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fpath), 0o750))
+		require.NoError(t, os.WriteFile(fpath, []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestBuildHash(t *testing.T) {
+	dir := writeBuildContext(t, map[string]string{
+		"Dockerfile":       "FROM alpine\nCOPY . /app\n",
+		".dockerignore":    "# comment\n/tmp\n*.log\n!keep.log\n",
+		"main.go":          "package main\n",
+		"tmp/cache":        "a",
+		"debug.log":        "a",
+		"keep.log":         "a",
+		"cmd/tool/main.go": "package main\n",
+	})
+	opts := &BuildOptions{
+		ContextDir: dir,
+		BuildArgs:  []docker.BuildArg{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}},
+	}
+	hash, err := buildHash(opts)
+	require.NoError(t, err)
+	assert.Len(t, hash, 64)
+
+	rehash := func(t *testing.T, opts *BuildOptions) string {
+		h, err := buildHash(opts)
+		require.NoError(t, err)
+		return h
+	}
+	write := func(t *testing.T, name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	t.Run("stable", func(t *testing.T) {
+		reordered := *opts
+		reordered.BuildArgs = []docker.BuildArg{{Name: "B", Value: "2"}, {Name: "A", Value: "1"}}
+		assert.Equal(t, hash, rehash(t, &reordered))
+	})
+	t.Run("ignored files", func(t *testing.T) {
+		write(t, "tmp/cache", "b")
+		write(t, "debug.log", "b")
+		assert.Equal(t, hash, rehash(t, opts))
+	})
+	t.Run("build options", func(t *testing.T) {
+		for _, o := range []BuildOptions{
+			{ContextDir: dir, BuildArgs: []docker.BuildArg{{Name: "A", Value: "1"}}},
+			{ContextDir: dir, BuildArgs: opts.BuildArgs, Target: "test"},
+			{ContextDir: dir, BuildArgs: opts.BuildArgs, Platform: "linux/arm64"},
+		} {
+			assert.NotEqual(t, hash, rehash(t, &o))
+		}
+	})
+	t.Run("secrets", func(t *testing.T) {
+		withSecret := *opts
+		withSecret.Secrets = []BuildSecret{{ID: "token", Src: filepath.Join(dir, "main.go")}}
+		assert.Equal(t, hash, rehash(t, &withSecret))
+	})
+	t.Run("content", func(t *testing.T) {
+		for _, name := range []string{"keep.log", "cmd/tool/main.go", "Dockerfile"} {
+			write(t, name, "changed")
+			changed := rehash(t, opts)
+			assert.NotEqual(t, hash, changed, name)
+			hash = changed
+		}
+	})
+}
+
+func TestBuildHashMissingDockerfile(t *testing.T) {
+	_, err := buildHash(&BuildOptions{ContextDir: t.TempDir(), Dockerfile: "build/Dockerfile"})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDockerignorePatterns(t *testing.T) {
+	dir := writeBuildContext(t, map[string]string{".dockerignore": "\n# comment\n/vendor/\n!vendor/keep\n  *.md  \n"})
+	patterns, err := dockerignorePatterns(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vendor", "!vendor/keep", "*.md"}, patterns)
+
+	patterns, err = dockerignorePatterns(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, patterns)
+}
@@ -1,13 +1,7 @@
 // Package awsmock exposes an AWS mock service backed by motoserver/moto.
 package awsmock
 
-import (
-	"context"
-	"fmt"
-	"net/http"
-
-	"github.com/beatlabs/bake/docker"
-)
+import "github.com/beatlabs/bake/docker"
 
 const (
 	// ServiceName is the advertised name of this service.
@@ -25,7 +19,7 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 		ServicePorts: map[string]string{
 			ServiceName: "5000",
 		},
-		ReadyFunc: readyFunc,
+		ReadyContextFunc: docker.WaitForHTTP(ServiceName, "/moto-api/"),
 	}
 
 	for _, opt := range opts {
@@ -37,29 +31,3 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 		Containers: []docker.SimpleContainerConfig{container},
 	}
 }
-
-func readyFunc(session *docker.Session) error {
-	addr, err := session.AutoServiceAddress(ServiceName)
-	if err != nil {
-		return err
-	}
-
-	return docker.Retry(func() error {
-		url := fmt.Sprintf("http://%s/moto-api/", addr)
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create health request: %w", err)
-		}
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return err
-		}
-		defer func() { _ = resp.Body.Close() }()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("got status code: %d from %s", resp.StatusCode, url)
-		}
-		return nil
-	})
-}
@@ -4,9 +4,12 @@ package component
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
+	"os/signal"
 	"testing"
 	"time"
 
@@ -27,48 +30,46 @@ import (
 var session *docker.Session
 
 func TestMain(m *testing.M) {
+	// Interrupting the setup aborts any pending pulls, runs and readiness checks.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	// Reuse a persisted session, restarting the components whose containers were stopped or removed since.
 	var err error
-	session, err = docker.LoadSession()
-	if err != nil {
-		newSession()
+	session, err = docker.LoadHealthySession(ctx, docker.DefaultSessionFile, docker.RestartUnhealthy, components())
+	if errors.Is(err, fs.ErrNotExist) {
+		newSession(ctx)
+	} else {
+		checkErr(err)
 	}
+	stop()
 
 	os.Exit(m.Run())
 }
 
-func newSession() {
-	sessionID, netID, err := docker.GetEnv()
-	checkErr(err)
-
-	sessionID += "-bake"
-
-	session, err = docker.NewSession(sessionID, netID)
-	checkErr(err)
-
-	err = session.StartComponents(
-		kafka.NewComponent(session, kafka.WithTopics("foo:1:1")),
+func components() []docker.ContextComponent {
+	return []docker.ContextComponent{
+		kafka.New(kafka.WithTopics("foo:1:1")),
 		consul.NewComponent(docker.WithTag("1.8.0")),
 		jaeger.NewComponent(),
 		awsmock.NewComponent(),
 		mockserver.NewComponent(),
 		redis.NewComponent(),
 		mongodb.NewComponent(),
-	)
-	checkErr(err)
-
-	redisAddr, err := session.DockerToDockerServiceAddress(redis.ServiceName)
-	checkErr(err)
+		// Started once redis, mongo and kafka are ready.
+		testservice.NewLinkedComponent(),
+	}
+}
 
-	mongoAddr, err := session.DockerToDockerServiceAddress(mongodb.ServiceName)
+func newSession(ctx context.Context) {
+	sessionID, netID, err := docker.GetEnv()
 	checkErr(err)
 
-	kafkaAddr, err := session.DockerToDockerServiceAddress(kafka.KafkaServiceName)
-	checkErr(err)
+	sessionID += "-bake"
 
-	serviceComponent, err := testservice.NewComponent(redisAddr, mongoAddr, kafkaAddr)
+	session, err = docker.NewSession(sessionID, netID)
 	checkErr(err)
 
-	err = session.StartComponents(serviceComponent)
+	err = session.StartComponentsContext(ctx, components()...)
 	checkErr(err)
 
 	// Optional: Store snapshot to filesystem.
@@ -156,6 +157,8 @@ func TestMockServer(t *testing.T) {
 }
 
 func TestExampleService(t *testing.T) {
+	docker.CaptureLogsOnFailure(t, session, testservice.ServiceName)
+
 	testServiceAddr, err := session.AutoServiceAddress(testservice.ServiceName)
 	require.NoError(t, err)
 
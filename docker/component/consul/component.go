@@ -2,6 +2,8 @@
 package consul
 
 import (
+	"context"
+
 	"github.com/beatlabs/bake/docker"
 )
 
@@ -20,7 +22,7 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 		ServicePorts: map[string]string{
 			ServiceName: "8500",
 		},
-		ReadyFunc: readyFunc,
+		ReadyContextFunc: readyFunc,
 	}
 
 	for _, opt := range opts {
@@ -33,7 +35,7 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 	}
 }
 
-func readyFunc(session *docker.Session) error {
+func readyFunc(ctx context.Context, session *docker.Session) error {
 	addr, err := session.AutoServiceAddress(ServiceName)
 	if err != nil {
 		return err
@@ -44,5 +46,5 @@ func readyFunc(session *docker.Session) error {
 		return err
 	}
 
-	return docker.Retry(consulClient.Live)
+	return docker.RetryContext(ctx, consulClient.Live)
 }
@@ -1,30 +1,38 @@
 // Package jaeger exposes a Jaeger service.
 package jaeger
 
-import (
-	"context"
-	"fmt"
-	"net/http"
-
-	"github.com/beatlabs/bake/docker"
-)
+import "github.com/beatlabs/bake/docker"
 
 const (
-	// ServiceName is the advertised name of this service.
-	ServiceName   = "jaeger"
-	componentName = "jaeger"
+	// ServiceName is the advertised name of this service, reachable at the UI and query port.
+	ServiceName = "jaeger"
+	// UIServiceName is the advertised name of the UI and query endpoint.
+	UIServiceName = "jaeger.ui"
+	// OTLPGRPCServiceName is the advertised name of the OTLP gRPC collector endpoint.
+	OTLPGRPCServiceName = "jaeger.otlp-grpc"
+	// OTLPHTTPServiceName is the advertised name of the OTLP HTTP collector endpoint.
+	OTLPHTTPServiceName = "jaeger.otlp-http"
+	// AgentCompactServiceName is the advertised name of the agent endpoint accepting compact Thrift over UDP.
+	AgentCompactServiceName = "jaeger.agent-compact"
+	componentName           = "jaeger"
 )
 
-// NewComponent creates a new Redis component.
+// NewComponent creates a new Jaeger component.
 func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleComponent {
+	ui := docker.Endpoint{Port: "16686", Scheme: "http"}
 	container := docker.SimpleContainerConfig{
 		Name:       componentName,
 		Repository: "jaegertracing/all-in-one",
 		Tag:        "latest",
-		ServicePorts: map[string]string{
-			ServiceName: "16686",
+		Env:        []string{"COLLECTOR_OTLP_ENABLED=true"},
+		Endpoints: map[string]docker.Endpoint{
+			ServiceName:             ui,
+			UIServiceName:           ui,
+			OTLPGRPCServiceName:     {Port: "4317", Scheme: "grpc"},
+			OTLPHTTPServiceName:     {Port: "4318", Scheme: "http"},
+			AgentCompactServiceName: {Port: "6831", Protocol: docker.UDP},
 		},
-		ReadyFunc: readyFunc,
+		ReadyContextFunc: docker.WaitForHTTP(ServiceName, "/health"),
 	}
 
 	for _, opt := range opts {
@@ -36,28 +44,3 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 		Containers: []docker.SimpleContainerConfig{container},
 	}
 }
-
-func readyFunc(session *docker.Session) error {
-	addr, err := session.AutoServiceAddress(ServiceName)
-	if err != nil {
-		return err
-	}
-
-	return docker.Retry(func() error {
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fmt.Sprintf("http://%s/health", addr), nil)
-		if err != nil {
-			return fmt.Errorf("failed to create health request: %w", err)
-		}
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return err
-		}
-		defer func() { _ = resp.Body.Close() }()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("got status code: %d", resp.StatusCode)
-		}
-		return nil
-	})
-}
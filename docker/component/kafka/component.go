@@ -2,8 +2,9 @@
 package kafka
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"net"
 	"strings"
 
 	"github.com/IBM/sarama"
@@ -26,8 +27,15 @@ func WithTopics(topics ...string) docker.SimpleContainerOptionFunc {
 	}
 }
 
-// NewComponent creates a new Redis component.
-func NewComponent(session *docker.Session, opts ...docker.SimpleContainerOptionFunc) *docker.SimpleComponent {
+// NewComponent creates a new Kafka component.
+//
+// Deprecated: Kafka reaches Zookeeper through its network alias, the session is not needed any more. Use New.
+func NewComponent(_ *docker.Session, opts ...docker.SimpleContainerOptionFunc) *docker.SimpleComponent {
+	return New(opts...)
+}
+
+// New creates a new Kafka component.
+func New(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleComponent {
 	zooContainer := docker.SimpleContainerConfig{
 		Name:       "zookeeper",
 		Repository: "wurstmeister/zookeeper",
@@ -35,11 +43,9 @@ func NewComponent(session *docker.Session, opts ...docker.SimpleContainerOptionF
 		ServicePorts: map[string]string{
 			ZookeeperServiceName: "2181",
 		},
-		ReadyFunc: zookeeperReadyFunc,
+		ReadyContextFunc: docker.WaitForTCP(ZookeeperServiceName),
 	}
 
-	port, _ := docker.GetFreePort()
-
 	kafkaContainer := docker.SimpleContainerConfig{
 		Name:       "kafka",
 		Repository: "wurstmeister/kafka",
@@ -47,17 +53,22 @@ func NewComponent(session *docker.Session, opts ...docker.SimpleContainerOptionF
 		ServicePorts: map[string]string{
 			KafkaServiceName: "9092",
 		},
-		StaticServicePorts: map[string]string{
-			KafkaServiceName: port,
+		// The outside listener is advertised to clients on the host, so its port must be known in advance.
+		PreassignedHostPorts: map[string]func(string, string) []string{
+			KafkaServiceName: func(host, port string) []string {
+				return []string{
+					"KAFKA_LISTENERS=INSIDE://:9092,OUTSIDE://:" + port,
+					"KAFKA_ADVERTISED_LISTENERS=INSIDE://:9092,OUTSIDE://" + net.JoinHostPort(host, port),
+				}
+			},
 		},
 		Env: []string{
-			fmt.Sprintf("KAFKA_ZOOKEEPER_CONNECT=%s-zookeeper:2181", session.ID()),
-			"KAFKA_LISTENERS=INSIDE://:9092,OUTSIDE://:" + port,
-			"KAFKA_ADVERTISED_LISTENERS=INSIDE://:9092,OUTSIDE://localhost:" + port,
+			// Zookeeper is reached through its network alias.
+			"KAFKA_ZOOKEEPER_CONNECT=zookeeper:2181",
 			"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP=INSIDE:PLAINTEXT,OUTSIDE:PLAINTEXT",
 			"KAFKA_INTER_BROKER_LISTENER_NAME=INSIDE",
 		},
-		ReadyFunc: kafkaReadyFunc,
+		ReadyContextFunc: kafkaReadyFunc,
 	}
 
 	for _, opt := range opts {
@@ -70,17 +81,13 @@ func NewComponent(session *docker.Session, opts ...docker.SimpleContainerOptionF
 	}
 }
 
-func zookeeperReadyFunc(_ *docker.Session) error {
-	return nil
-}
-
-func kafkaReadyFunc(session *docker.Session) error {
+func kafkaReadyFunc(ctx context.Context, session *docker.Session) error {
 	addr, err := session.AutoServiceAddress(KafkaServiceName)
 	if err != nil {
 		return err
 	}
 
-	return docker.Retry(func() error {
+	return docker.RetryContext(ctx, func() error {
 		cl, err := sarama.NewClient([]string{addr}, nil)
 		if err != nil {
 			return err
@@ -2,8 +2,6 @@
 package mockserver
 
 import (
-	"context"
-	"fmt"
 	"net/http"
 
 	"github.com/beatlabs/bake/docker"
@@ -27,7 +25,7 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 		ServicePorts: map[string]string{
 			ServiceName: "1080",
 		},
-		ReadyFunc: readyFunc,
+		ReadyContextFunc: docker.WaitForHTTP(ServiceName, "/status", docker.WithMethod(http.MethodPut)),
 	}
 
 	for _, opt := range opts {
@@ -39,28 +37,3 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 		Containers: []docker.SimpleContainerConfig{container},
 	}
 }
-
-func readyFunc(session *docker.Session) error {
-	addr, err := session.AutoServiceAddress(ServiceName)
-	if err != nil {
-		return err
-	}
-
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, fmt.Sprintf("http://%s/status", addr), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create status request to mockserver: %w", err)
-	}
-
-	return docker.Retry(func() error {
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("could not connect to mockserver: %w", err)
-		}
-		defer func() { _ = resp.Body.Close() }()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("got status code: %d", resp.StatusCode)
-		}
-		return nil
-	})
-}
@@ -26,8 +26,8 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 			ServiceName: "27017",
 		},
 
-		ReadyFunc: readyFunc,
-		Env:       []string{},
+		ReadyContextFunc: readyFunc,
+		Env:              []string{},
 		RunOpts: &docker.RunOptions{
 			Cmd:         []string{"--replSet", ReplicaSet},
 			InitExecCmd: `mongo --eval "rs.initiate()"`,
@@ -44,18 +44,18 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 	}
 }
 
-func readyFunc(session *docker.Session) error {
+func readyFunc(ctx context.Context, session *docker.Session) error {
 	addr, err := session.AutoServiceAddress(ServiceName)
 	if err != nil {
 		return err
 	}
 
-	return docker.Retry(func() error {
-		cl, err := NewClient(context.Background(), addr)
+	return docker.RetryContext(ctx, func() error {
+		cl, err := NewClient(ctx, addr)
 		if err != nil {
 			return fmt.Errorf("failed to create mongo client: %w", err)
 		}
-		defer func() { _ = cl.Disconnect(context.Background()) }()
-		return cl.Ping(context.Background(), nil)
+		defer func() { _ = cl.Disconnect(ctx) }()
+		return cl.Ping(ctx, nil)
 	})
 }
@@ -0,0 +1,61 @@
+//go:build component
+
+package component
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/beatlabs/bake/docker"
+	"github.com/beatlabs/bake/docker/component/redis"
+	dockerclient "github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// podmanSocket finds a local Podman socket, set with BAKE_PODMAN_SOCKET or at its rootless or rootful location.
+func podmanSocket() string {
+	candidates := []string{os.Getenv("BAKE_PODMAN_SOCKET")}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, "/run/podman/podman.sock")
+	for _, socket := range candidates {
+		if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return socket
+		}
+	}
+	return ""
+}
+
+func TestPodman(t *testing.T) {
+	socket := podmanSocket()
+	if socket == "" {
+		t.Skip("no Podman socket found")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	client, err := dockerclient.NewClient("unix://" + socket)
+	require.NoError(t, err)
+	rt := docker.NewDockerRuntime(client)
+	netID, err := rt.CreateNetwork(ctx, "bake-podman-test", nil)
+	require.NoError(t, err)
+
+	s, err := docker.NewSession("bake-podman-test", netID, docker.WithDockerClient(client))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := docker.CleanupSessionResourcesContext(context.Background(), s)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "localhost", s.HostAddress())
+
+	require.NoError(t, s.StartComponentsContext(ctx, redis.NewComponent()))
+
+	addr, err := s.HostToDockerServiceAddress(redis.ServiceName)
+	require.NoError(t, err)
+	require.NoError(t, redis.NewClient(addr).Ping(ctx).Err())
+}
@@ -23,7 +23,7 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 		ServicePorts: map[string]string{
 			ServiceName: "6379",
 		},
-		ReadyFunc: readyFunc,
+		ReadyContextFunc: readyFunc,
 		// Disable redis protected mode, in this mode connections are only accepted from the loopback interface
 		RunOpts: &docker.RunOptions{
 			Cmd: []string{"redis-server", "--protected-mode", "no"},
@@ -40,7 +40,7 @@ func NewComponent(opts ...docker.SimpleContainerOptionFunc) *docker.SimpleCompon
 	}
 }
 
-func readyFunc(session *docker.Session) error {
+func readyFunc(ctx context.Context, session *docker.Session) error {
 	addr, err := session.AutoServiceAddress(ServiceName)
 	if err != nil {
 		return err
@@ -49,8 +49,8 @@ func readyFunc(session *docker.Session) error {
 	opts := &redis.Options{Addr: addr}
 	cl := redis.NewClient(opts)
 
-	return docker.Retry(func() error {
-		_, err := cl.Ping(context.Background()).Result()
+	return docker.RetryContext(ctx, func() error {
+		_, err := cl.Ping(ctx).Result()
 		return err
 	})
 }
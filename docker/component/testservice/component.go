@@ -2,11 +2,10 @@
 package testservice
 
 import (
-	"context"
-	"fmt"
-	"net/http"
-
 	"github.com/beatlabs/bake/docker"
+	"github.com/beatlabs/bake/docker/component/kafka"
+	"github.com/beatlabs/bake/docker/component/mongodb"
+	"github.com/beatlabs/bake/docker/component/redis"
 )
 
 const (
@@ -17,6 +16,24 @@ const (
 
 // NewComponent constructs a component.
 func NewComponent(redisAddr, mongoAddr, kafkaAddr string) (*docker.SimpleComponent, error) {
+	return newComponent([]string{
+		"REDIS=" + redisAddr,
+		"MONGO=" + mongoAddr,
+		"KAFKA=" + kafkaAddr,
+	}, nil), nil
+}
+
+// NewLinkedComponent constructs a component which depends on the Redis, Mongo and Kafka services of the session.
+// Their addresses are resolved once they are ready, so it can be started alongside them.
+func NewLinkedComponent() *docker.SimpleComponent {
+	return newComponent(nil, map[string]string{
+		"REDIS": redis.ServiceName,
+		"MONGO": mongodb.ServiceName,
+		"KAFKA": kafka.KafkaServiceName,
+	})
+}
+
+func newComponent(env []string, serviceEnv map[string]string) *docker.SimpleComponent {
 	container := docker.SimpleContainerConfig{
 		BuildOpts: &docker.BuildOptions{
 			Dockerfile: "docker/component/testservice/Dockerfile",
@@ -24,45 +41,16 @@ func NewComponent(redisAddr, mongoAddr, kafkaAddr string) (*docker.SimpleCompone
 		},
 		Name:       componentName,
 		Repository: componentName,
-		Env: []string{
-			"REDIS=" + redisAddr,
-			"MONGO=" + mongoAddr,
-			"KAFKA=" + kafkaAddr,
-			"PORT=8080",
-		},
+		Env:        append(env, "PORT=8080"),
+		ServiceEnv: serviceEnv,
 		ServicePorts: map[string]string{
 			ServiceName: "8080",
 		},
-		ReadyFunc: readyFunc,
+		ReadyContextFunc: docker.WaitForHTTP(ServiceName, "/health"),
 	}
 
 	return &docker.SimpleComponent{
 		Name:       componentName,
 		Containers: []docker.SimpleContainerConfig{container},
-	}, nil
-}
-
-func readyFunc(session *docker.Session) error {
-	addr, err := session.AutoServiceAddress(ServiceName)
-	if err != nil {
-		return err
 	}
-
-	return docker.Retry(func() error {
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fmt.Sprintf("http://%s/health", addr), nil)
-		if err != nil {
-			return fmt.Errorf("failed to create health request: %w", err)
-		}
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return err
-		}
-		defer func() { _ = resp.Body.Close() }()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("got status code: %d", resp.StatusCode)
-		}
-		return nil
-	})
 }
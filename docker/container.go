@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// containerOptions mirrors dockertest's Pool.RunWithOptions, turning run options into the options a Runtime
+// creates a container from. The configure funcs customize the container and host config beyond what dockertest
+// supports.
+func containerOptions(opts *dockertest.RunOptions,
+	configure ...func(*docker.Config, *docker.HostConfig),
+) docker.CreateContainerOptions {
+	tag := opts.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	var exposedPorts map[docker.Port]struct{}
+	if len(opts.ExposedPorts) > 0 {
+		exposedPorts = map[docker.Port]struct{}{}
+		for _, p := range opts.ExposedPorts {
+			exposedPorts[docker.Port(p)] = struct{}{}
+		}
+	}
+
+	networkingConfig := docker.NetworkingConfig{
+		EndpointsConfig: map[string]*docker.EndpointConfig{},
+	}
+	if opts.NetworkID != "" {
+		networkingConfig.EndpointsConfig[opts.NetworkID] = &docker.EndpointConfig{}
+	}
+
+	hostConfig := docker.HostConfig{
+		PublishAllPorts: true,
+		Binds:           opts.Mounts,
+		PortBindings:    opts.PortBindings,
+		ExtraHosts:      opts.ExtraHosts,
+		CapAdd:          opts.CapAdd,
+		SecurityOpt:     opts.SecurityOpt,
+		Privileged:      opts.Privileged,
+		DNS:             opts.DNS,
+	}
+	config := docker.Config{
+		Hostname:     opts.Hostname,
+		Image:        opts.Repository + ":" + tag,
+		Env:          opts.Env,
+		Entrypoint:   opts.Entrypoint,
+		Cmd:          opts.Cmd,
+		ExposedPorts: exposedPorts,
+		WorkingDir:   opts.WorkingDir,
+		Labels:       opts.Labels,
+		User:         opts.User,
+		Tty:          opts.Tty,
+	}
+	for _, configureFunc := range configure {
+		configureFunc(&config, &hostConfig)
+	}
+
+	return docker.CreateContainerOptions{
+		Name:             opts.Name,
+		Config:           &config,
+		HostConfig:       &hostConfig,
+		NetworkingConfig: &networkingConfig,
+	}
+}
@@ -0,0 +1,152 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DependentComponent is a ContextComponent which declares the components or services it depends on.
+// The session starts it only once all of its dependencies are ready.
+type DependentComponent interface {
+	ContextComponent
+	// ComponentName identifies the component in the dependency graph.
+	ComponentName() string
+	// ServiceNames lists the services the component registers once started.
+	ServiceNames() []string
+	// Dependencies lists component or service names which must be ready before the component starts.
+	Dependencies() []string
+}
+
+type startNode struct {
+	name      string
+	component ContextComponent
+	deps      []*startNode
+	done      chan struct{}
+}
+
+// planStartup builds the dependency graph of the components.
+// It fails on duplicate component names, missing dependencies and cycles.
+func (s *Session) planStartup(cs []ContextComponent) ([]*startNode, error) {
+	nodes := make([]*startNode, 0, len(cs))
+	byName := map[string]*startNode{}
+	byService := map[string]*startNode{}
+
+	for i, c := range cs {
+		n := &startNode{name: fmt.Sprintf("component #%d", i), component: c, done: make(chan struct{})}
+		if dc, ok := c.(DependentComponent); ok {
+			n.name = dc.ComponentName()
+			if _, ok := byName[n.name]; ok {
+				return nil, fmt.Errorf("duplicate component %q", n.name)
+			}
+			byName[n.name] = n
+			for _, svc := range dc.ServiceNames() {
+				if other, ok := byService[svc]; ok {
+					return nil, fmt.Errorf("service %q is provided by both %q and %q", svc, other.name, n.name)
+				}
+				byService[svc] = n
+			}
+		}
+		nodes = append(nodes, n)
+	}
+
+	for _, n := range nodes {
+		dc, ok := n.component.(DependentComponent)
+		if !ok {
+			continue
+		}
+		for _, dep := range dc.Dependencies() {
+			depNode, ok := byName[dep]
+			if !ok {
+				depNode, ok = byService[dep]
+			}
+			if ok {
+				if depNode != n {
+					n.deps = append(n.deps, depNode)
+				}
+				continue
+			}
+			if s.hasService(dep) {
+				// Already started by a previous call.
+				continue
+			}
+			return nil, fmt.Errorf("component %q depends on unknown component or service %q", n.name, dep)
+		}
+	}
+
+	if cycle := findCycle(nodes); len(cycle) > 0 {
+		return nil, fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nodes, nil
+}
+
+// findCycle returns the component names forming a dependency cycle, if any.
+func findCycle(nodes []*startNode) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[*startNode]int{}
+	var path []*startNode
+
+	var visit func(n *startNode) []string
+	visit = func(n *startNode) []string {
+		state[n] = visiting
+		path = append(path, n)
+		for _, dep := range n.deps {
+			switch state[dep] {
+			case visiting:
+				var cycle []string
+				for i := len(path) - 1; i >= 0; i-- {
+					cycle = append([]string{path[i].name}, cycle...)
+					if path[i] == dep {
+						break
+					}
+				}
+				return append(cycle, dep.name)
+			case unvisited:
+				if cycle := visit(dep); len(cycle) > 0 {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+		return nil
+	}
+
+	for _, n := range nodes {
+		if state[n] == unvisited {
+			if cycle := visit(n); len(cycle) > 0 {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// startGraph starts every node as soon as its dependencies are ready, independent branches run in parallel.
+func (s *Session) startGraph(ctx context.Context, nodes []*startNode) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, n := range nodes {
+		g.Go(func() error {
+			for _, dep := range n.deps {
+				select {
+				case <-dep.done:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if err := n.component.StartContext(ctx, s); err != nil {
+				return err
+			}
+			close(n.done)
+			return nil
+		})
+	}
+	return g.Wait()
+}
@@ -0,0 +1,149 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeComponent struct {
+	name     string
+	services []string
+	deps     []string
+	err      error
+	mu       *sync.Mutex
+	started  *[]string
+}
+
+func (c fakeComponent) StartContext(_ context.Context, s *Session) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.mu.Lock()
+	*c.started = append(*c.started, c.name)
+	c.mu.Unlock()
+	for _, svc := range c.services {
+		if err := s.RegisterInternalDockerService(svc, c.name+":1"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c fakeComponent) Stop(context.Context, *Session) error { return nil }
+
+func (c fakeComponent) ComponentName() string { return c.name }
+
+func (c fakeComponent) ServiceNames() []string { return c.services }
+
+func (c fakeComponent) Dependencies() []string { return c.deps }
+
+func newFakeComponents(cs ...fakeComponent) (*[]string, []ContextComponent) {
+	started := &[]string{}
+	mu := &sync.Mutex{}
+	ccs := make([]ContextComponent, 0, len(cs))
+	for _, c := range cs {
+		c.mu = mu
+		c.started = started
+		ccs = append(ccs, c)
+	}
+	return started, ccs
+}
+
+func newTestSession() *Session {
+	return &Session{serviceAddresses: map[string]string{}, hostMappedServiceAddresses: map[string]string{}}
+}
+
+func TestStartComponentsDependencyOrder(t *testing.T) {
+	started, cs := newFakeComponents(
+		fakeComponent{name: "service", deps: []string{"redis-svc", "kafka"}},
+		fakeComponent{name: "kafka", services: []string{"kafka-svc"}},
+		fakeComponent{name: "redis", services: []string{"redis-svc"}},
+	)
+
+	err := newTestSession().StartComponentsContext(context.Background(), cs...)
+	require.NoError(t, err)
+	require.Len(t, *started, 3)
+	assert.Equal(t, "service", (*started)[2])
+}
+
+func TestStartComponentsDependencyErrors(t *testing.T) {
+	testCases := map[string]struct {
+		components []fakeComponent
+		expErr     string
+	}{
+		"missing dependency": {
+			components: []fakeComponent{{name: "a", deps: []string{"b"}}},
+			expErr:     `component "a" depends on unknown component or service "b"`,
+		},
+		"cycle": {
+			components: []fakeComponent{
+				{name: "a", deps: []string{"b"}},
+				{name: "b", deps: []string{"c-svc"}},
+				{name: "c", services: []string{"c-svc"}, deps: []string{"a"}},
+			},
+			expErr: "dependency cycle detected: a -> b -> c -> a",
+		},
+		"duplicate component": {
+			components: []fakeComponent{{name: "a"}, {name: "a"}},
+			expErr:     `duplicate component "a"`,
+		},
+		"duplicate service": {
+			components: []fakeComponent{{name: "a", services: []string{"s"}}, {name: "b", services: []string{"s"}}},
+			expErr:     `service "s" is provided by both "a" and "b"`,
+		},
+	}
+
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			started, cs := newFakeComponents(tt.components...)
+			err := newTestSession().StartComponentsContext(context.Background(), cs...)
+			require.EqualError(t, err, tt.expErr)
+			assert.Empty(t, *started)
+		})
+	}
+}
+
+func TestStartComponentsPreviouslyStartedDependency(t *testing.T) {
+	sess := newTestSession()
+	require.NoError(t, sess.RegisterInternalDockerService("redis", "000-redis:6379"))
+
+	started, cs := newFakeComponents(fakeComponent{name: "service", deps: []string{"redis"}})
+	err := sess.StartComponentsContext(context.Background(), cs...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"service"}, *started)
+}
+
+func TestStartComponentsFailedDependency(t *testing.T) {
+	errBoom := errors.New("boom")
+	started, cs := newFakeComponents(
+		fakeComponent{name: "service", deps: []string{"redis"}},
+		fakeComponent{name: "redis", err: errBoom},
+	)
+
+	err := newTestSession().StartComponentsContext(context.Background(), cs...)
+	require.ErrorIs(t, err, errBoom)
+	assert.Empty(t, *started)
+}
+
+func TestSimpleComponentDependencies(t *testing.T) {
+	c := &SimpleComponent{
+		Name: "kafka",
+		Containers: []SimpleContainerConfig{
+			{Name: "zookeeper", ServicePorts: map[string]string{"zookeeper": "2181"}},
+			{
+				Name:         "kafka",
+				ServicePorts: map[string]string{"kafka": "9092"},
+				DependsOn:    []string{"zookeeper", "consul"},
+				ServiceEnv:   map[string]string{"REDIS": "redis", "CONSUL": "consul"},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"kafka", "zookeeper"}, c.ServiceNames())
+	assert.Equal(t, []string{"consul", "redis"}, c.Dependencies())
+}
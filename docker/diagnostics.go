@@ -0,0 +1,199 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// diagnosticsTimeout bounds collecting diagnostics after a failed start, the startup deadline may be over already.
+const diagnosticsTimeout = 30 * time.Second
+
+// StartupError describes a container which failed to start, collected for troubleshooting.
+// It wraps the error which made the start fail.
+type StartupError struct {
+	Component   string `json:"component"`
+	Container   string `json:"container"`
+	ContainerID string `json:"containerId,omitempty"`
+	Image       string `json:"image"`
+	// ImageDigest is the registry digest of the image, empty for images without one, e.g. built ones.
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// ImageID is the local ID of the image.
+	ImageID   string              `json:"imageId,omitempty"`
+	State     string              `json:"state"`
+	ExitCode  int                 `json:"exitCode"`
+	OOMKilled bool                `json:"oomKilled"`
+	Ports     map[string][]string `json:"ports,omitempty"`
+	Networks  []string            `json:"networks,omitempty"`
+	// Logs holds the last FailureLogTail log lines of the container.
+	Logs string `json:"logs,omitempty"`
+	// ReadinessErr is the last error of the readiness checks, if the container did not become ready.
+	ReadinessErr error `json:"-"`
+	Err          error `json:"-"`
+}
+
+func (e *StartupError) Error() string {
+	return fmt.Sprintf("container %s: %v", e.Container, e.Err)
+}
+
+func (e *StartupError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON includes the error messages, e.g. to store the diagnostics as CI artifacts.
+func (e *StartupError) MarshalJSON() ([]byte, error) {
+	type diagnostics StartupError
+	errMsg := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	return json.Marshal(struct {
+		*diagnostics
+		ReadinessErr string `json:"readinessError,omitempty"`
+		Err          string `json:"error"`
+	}{
+		diagnostics:  (*diagnostics)(e),
+		ReadinessErr: errMsg(e.ReadinessErr),
+		Err:          errMsg(e.Err),
+	})
+}
+
+// Report renders the diagnostics as a human readable block.
+func (e *StartupError) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== Startup of container %s (component %s) failed\n", e.Container, e.Component)
+	fmt.Fprintf(&b, "Error:        %v\n", e.Err)
+	fmt.Fprintf(&b, "Container ID: %s\n", e.ContainerID)
+	fmt.Fprintf(&b, "Image:        %s\n", e.Image)
+	if e.ImageDigest != "" {
+		fmt.Fprintf(&b, "Image digest: %s\n", e.ImageDigest)
+	}
+	fmt.Fprintf(&b, "Image ID:     %s\n", e.ImageID)
+	fmt.Fprintf(&b, "State:        %s (exit code %d, OOM killed %t)\n", e.State, e.ExitCode, e.OOMKilled)
+
+	ports := make([]string, 0, len(e.Ports))
+	for port, bindings := range e.Ports {
+		ports = append(ports, port+" -> "+strings.Join(bindings, ", "))
+	}
+	sort.Strings(ports)
+	fmt.Fprintf(&b, "Ports:        %s\n", strings.Join(ports, "; "))
+	fmt.Fprintf(&b, "Networks:     %s\n", strings.Join(e.Networks, ", "))
+	if e.ReadinessErr != nil {
+		fmt.Fprintf(&b, "Readiness:    %v\n", e.ReadinessErr)
+	}
+	if e.Logs != "" {
+		fmt.Fprintf(&b, "Last log lines:\n%s\n", e.Logs)
+	}
+	b.WriteString("===\n")
+	return b.String()
+}
+
+// readinessError marks errors of the readiness checks, it does not change the error message.
+type readinessError struct {
+	err error
+}
+
+func (e *readinessError) Error() string {
+	return e.err.Error()
+}
+
+func (e *readinessError) Unwrap() error {
+	return e.err
+}
+
+// diagnoseStartup collects the diagnostics of a container which failed to start.
+func diagnoseStartup(ctx context.Context, rt Runtime, component, containerName, image string,
+	err error,
+) *StartupError {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnosticsTimeout)
+	defer cancel()
+
+	d := &StartupError{
+		Component: component,
+		Container: containerName,
+		Image:     image,
+		State:     "missing",
+		Err:       err,
+	}
+	var notReady *readinessError
+	if errors.As(err, &notReady) {
+		d.ReadinessErr = notReady.err
+	}
+
+	c, inspectErr := rt.InspectContainer(ctx, containerName)
+	if inspectErr != nil {
+		return d
+	}
+	d.fillFromContainer(c)
+
+	if c.Config != nil && c.Config.Image != "" {
+		d.ImageDigest = imageDigest(ctx, rt, parseImageRef(c.Config.Image))
+	}
+
+	var logs bytes.Buffer
+	if err := rt.ContainerLogs(ctx, c.ID, &logs, LogOptions{Tail: FailureLogTail}); err == nil {
+		d.Logs = strings.TrimRight(logs.String(), "\n")
+	}
+	return d
+}
+
+func (e *StartupError) fillFromContainer(c *docker.Container) {
+	e.ContainerID = c.ID
+	e.ImageID = c.Image
+	if c.Config != nil && c.Config.Image != "" {
+		e.Image = c.Config.Image
+	}
+	e.State = c.State.Status
+	e.ExitCode = c.State.ExitCode
+	e.OOMKilled = c.State.OOMKilled
+
+	if c.NetworkSettings == nil {
+		return
+	}
+	e.Ports = map[string][]string{}
+	for port, bindings := range c.NetworkSettings.Ports {
+		hostAddrs := make([]string, 0, len(bindings))
+		for _, binding := range bindings {
+			hostAddrs = append(hostAddrs, binding.HostIP+":"+binding.HostPort)
+		}
+		e.Ports[string(port)] = hostAddrs
+	}
+	for network := range c.NetworkSettings.Networks {
+		e.Networks = append(e.Networks, network)
+	}
+	sort.Strings(e.Networks)
+}
+
+// parseImageRef splits an image reference, e.g. "redis:7-alpine" or "redis@sha256:...", into its parts.
+func parseImageRef(ref string) ImageRef {
+	if repository, digest, ok := strings.Cut(ref, "@"); ok {
+		return ImageRef{Repository: repository, Digest: digest}
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ImageRef{Repository: ref[:i], Tag: ref[i+1:]}
+	}
+	return ImageRef{Repository: ref}
+}
+
+// writeStartupDiagnostics stores the diagnostics as JSON in <dir>/<container name>-startup.json.
+func writeStartupDiagnostics(dir string, d *StartupError) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, d.Container+"-startup.json"), data, 0o600)
+}
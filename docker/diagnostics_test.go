@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStartupError() *StartupError {
+	errNotReady := errors.New("connection refused")
+	d := &StartupError{
+		Component:    "redis",
+		Container:    "000-redis",
+		Image:        "redis:7-alpine",
+		ReadinessErr: errNotReady,
+		Err:          fmt.Errorf("context deadline exceeded: %w", errNotReady),
+	}
+	d.fillFromContainer(&docker.Container{
+		ID:    "abc",
+		Image: "sha256:def",
+		State: docker.State{Status: "running"},
+		NetworkSettings: &docker.NetworkSettings{
+			Ports: map[docker.Port][]docker.PortBinding{
+				"6379/tcp": {{HostIP: "0.0.0.0", HostPort: "49153"}},
+			},
+			Networks: map[string]docker.ContainerNetwork{"000": {}},
+		},
+	})
+	d.Logs = "Ready to accept connections"
+	return d
+}
+
+func TestStartupErrorReport(t *testing.T) {
+	d := testStartupError()
+
+	assert.EqualError(t, d, "container 000-redis: context deadline exceeded: connection refused")
+	assert.Equal(t, `=== Startup of container 000-redis (component redis) failed
+Error:        context deadline exceeded: connection refused
+Container ID: abc
+Image:        redis:7-alpine
+Image ID:     sha256:def
+State:        running (exit code 0, OOM killed false)
+Ports:        6379/tcp -> 0.0.0.0:49153
+Networks:     000
+Readiness:    connection refused
+Last log lines:
+Ready to accept connections
+===
+`, d.Report())
+}
+
+func TestStartupErrorJSON(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, writeStartupDiagnostics(dir, testStartupError()))
+
+	data, err := os.ReadFile(filepath.Join(dir, "000-redis-startup.json"))
+	require.NoError(t, err)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, "abc", got["containerId"])
+	assert.Equal(t, "sha256:def", got["imageId"])
+	assert.NotContains(t, got, "imageDigest")
+	assert.Equal(t, "connection refused", got["readinessError"])
+	assert.Equal(t, "context deadline exceeded: connection refused", got["error"])
+	assert.Equal(t, map[string]any{"6379/tcp": []any{"0.0.0.0:49153"}}, got["ports"])
+}
+
+func TestStartupErrorUnwrap(t *testing.T) {
+	exited := &ContainerExitedError{Container: "000-redis", ExitCode: 1}
+	err := fmt.Errorf("starting component %q: %w", "redis", &StartupError{Container: "000-redis", Err: exited})
+
+	var d *StartupError
+	require.ErrorAs(t, err, &d)
+	var e *ContainerExitedError
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, 1, e.ExitCode)
+}
+
+func TestParseImageRef(t *testing.T) {
+	tests := map[string]ImageRef{
+		"redis":                               {Repository: "redis"},
+		"redis:7-alpine":                      {Repository: "redis", Tag: "7-alpine"},
+		"localhost:5000/redis":                {Repository: "localhost:5000/redis"},
+		"localhost:5000/redis:7-alpine":       {Repository: "localhost:5000/redis", Tag: "7-alpine"},
+		"mirror.example.com/redis@sha256:abc": {Repository: "mirror.example.com/redis", Digest: "sha256:abc"},
+	}
+	for ref, want := range tests {
+		assert.Equal(t, want, parseImageRef(ref), ref)
+	}
+}
+
+func TestDiagnoseStartupImageDigest(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	errNotReady := errors.New("not ready")
+	c := &SimpleComponent{Name: "redis", Containers: []SimpleContainerConfig{{
+		Name:             "redis",
+		Repository:       "redis",
+		Tag:              "7-alpine",
+		ReadyContextFunc: func(context.Context, *Session) error { return errNotReady },
+	}}}
+	WithKeepOnFailure()(sess)
+	require.Error(t, sess.StartComponentsContext(context.Background(), c))
+
+	d := diagnoseStartup(context.Background(), rt, "redis", "000-redis", "redis:7-alpine", errNotReady)
+	container, err := rt.InspectContainer(context.Background(), "000-redis")
+	require.NoError(t, err)
+	assert.Equal(t, container.Image, d.ImageID)
+	img, err := rt.InspectImage(context.Background(), "redis:7-alpine")
+	require.NoError(t, err)
+	assert.Equal(t, img.RepoDigests, []string{"redis@" + d.ImageDigest})
+}
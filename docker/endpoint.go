@@ -0,0 +1,144 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Protocol is the transport protocol of an endpoint.
+type Protocol string
+
+const (
+	// TCP is the default protocol of endpoints.
+	TCP Protocol = "tcp"
+	// UDP is the protocol of endpoints such as Jaeger's compact Thrift agent.
+	UDP Protocol = "udp"
+)
+
+// Endpoint is a container port a service is reachable at, along with how to talk to it.
+type Endpoint struct {
+	Port string
+	// Protocol defaults to TCP.
+	Protocol Protocol
+	// Scheme is the URL scheme of the service URLs, e.g. "http" or "grpc". It defaults to the protocol.
+	Scheme string
+}
+
+// portSpec returns the Docker port of the endpoint, e.g. "6831/udp".
+func (e Endpoint) portSpec() string {
+	return e.Port + "/" + string(e.protocol())
+}
+
+func (e Endpoint) protocol() Protocol {
+	if e.Protocol == "" {
+		return TCP
+	}
+	return e.Protocol
+}
+
+func (e Endpoint) scheme() string {
+	if e.Scheme == "" {
+		return string(e.protocol())
+	}
+	return e.Scheme
+}
+
+// containerEndpoints merges the ServicePorts of a container, as TCP endpoints, with its Endpoints.
+// Endpoints take precedence for services listed in both.
+func containerEndpoints(conf SimpleContainerConfig) map[string]Endpoint {
+	endpoints := make(map[string]Endpoint, len(conf.ServicePorts)+len(conf.Endpoints))
+	for serviceName, port := range conf.ServicePorts {
+		endpoints[serviceName] = Endpoint{Port: port, Protocol: TCP}
+	}
+	for serviceName, e := range conf.Endpoints {
+		e.Protocol = e.protocol()
+		endpoints[serviceName] = e
+	}
+	return endpoints
+}
+
+// validateEndpoints checks the protocols of the endpoints and that ports needing a host port up front are TCP.
+func validateEndpoints(conf SimpleContainerConfig) error {
+	names := make([]string, 0, len(conf.Endpoints))
+	for serviceName := range conf.Endpoints {
+		names = append(names, serviceName)
+	}
+	sort.Strings(names)
+
+	for _, serviceName := range names {
+		e := conf.Endpoints[serviceName]
+		if e.Port == "" {
+			return fmt.Errorf("endpoint %s has no port", serviceName)
+		}
+		switch e.protocol() {
+		case TCP:
+		case UDP:
+			_, preassigned := conf.PreassignedHostPorts[serviceName]
+			_, static := conf.StaticServicePorts[serviceName]
+			if preassigned || static {
+				return fmt.Errorf("endpoint %s: fixed host ports are only supported for TCP", serviceName)
+			}
+		default:
+			return fmt.Errorf("endpoint %s: unknown protocol %q", serviceName, e.Protocol)
+		}
+	}
+	return nil
+}
+
+// RegisterServiceEndpoint records the endpoint metadata of a registered service, used to build its URLs.
+func (s *Session) RegisterServiceEndpoint(serviceName string, e Endpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.endpoints == nil {
+		s.endpoints = map[string]Endpoint{}
+	}
+	s.endpoints[serviceName] = e
+}
+
+// ServiceEndpoint returns the endpoint metadata of a service, services registered without any are TCP.
+func (s *Session) ServiceEndpoint(serviceName string) (Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.serviceAddresses[serviceName]; !ok {
+		return Endpoint{}, fmt.Errorf("internal service address not registered for %q", serviceName)
+	}
+	e, ok := s.endpoints[serviceName]
+	if !ok {
+		return Endpoint{Protocol: TCP}, nil
+	}
+	return e, nil
+}
+
+// DockerToDockerServiceURL retrieves an internal URL for a service name, e.g. "http://000-jaeger:16686".
+func (s *Session) DockerToDockerServiceURL(serviceName string) (string, error) {
+	return s.serviceURL(serviceName, s.DockerToDockerServiceAddress)
+}
+
+// HostToDockerServiceURL retrieves a host mapped URL for a service name, e.g. "http://localhost:32768".
+func (s *Session) HostToDockerServiceURL(serviceName string) (string, error) {
+	return s.serviceURL(serviceName, s.HostToDockerServiceAddress)
+}
+
+// AliasServiceURL retrieves an internal URL for a service name made of a network alias, e.g. "http://jaeger:16686".
+func (s *Session) AliasServiceURL(serviceName string) (string, error) {
+	return s.serviceURL(serviceName, s.AliasServiceAddress)
+}
+
+// AutoServiceURL retrieves a URL for a service name, appropriate for the running code.
+func (s *Session) AutoServiceURL(serviceName string) (string, error) {
+	return s.serviceURL(serviceName, s.AutoServiceAddress)
+}
+
+func (s *Session) serviceURL(serviceName string, address func(string) (string, error)) (string, error) {
+	addr, err := address(serviceName)
+	if err != nil {
+		return "", err
+	}
+	e, err := s.ServiceEndpoint(serviceName)
+	if err != nil {
+		return "", err
+	}
+	return e.scheme() + "://" + addr, nil
+}
@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerEndpoints(t *testing.T) {
+	conf := SimpleContainerConfig{
+		ServicePorts: map[string]string{"jaeger": "16686", "jaeger.ui": "80"},
+		Endpoints: map[string]Endpoint{
+			"jaeger.ui":            {Port: "16686", Scheme: "http"},
+			"jaeger.agent-compact": {Port: "6831", Protocol: UDP},
+		},
+	}
+
+	assert.Equal(t, map[string]Endpoint{
+		"jaeger":               {Port: "16686", Protocol: TCP},
+		"jaeger.ui":            {Port: "16686", Protocol: TCP, Scheme: "http"},
+		"jaeger.agent-compact": {Port: "6831", Protocol: UDP},
+	}, containerEndpoints(conf))
+	assert.Equal(t, []string{"jaeger", "jaeger.agent-compact", "jaeger.ui"}, (&SimpleComponent{
+		Containers: []SimpleContainerConfig{conf},
+	}).ServiceNames())
+}
+
+func TestValidateEndpoints(t *testing.T) {
+	tests := map[string]struct {
+		conf SimpleContainerConfig
+		err  string
+	}{
+		"valid": {
+			conf: SimpleContainerConfig{Endpoints: map[string]Endpoint{"a": {Port: "1"}, "b": {Port: "2", Protocol: UDP}}},
+		},
+		"no port": {
+			conf: SimpleContainerConfig{Endpoints: map[string]Endpoint{"a": {Scheme: "http"}}},
+			err:  "endpoint a has no port",
+		},
+		"unknown protocol": {
+			conf: SimpleContainerConfig{Endpoints: map[string]Endpoint{"a": {Port: "1", Protocol: "sctp"}}},
+			err:  `endpoint a: unknown protocol "sctp"`,
+		},
+		"fixed udp port": {
+			conf: SimpleContainerConfig{
+				Endpoints:          map[string]Endpoint{"a": {Port: "1", Protocol: UDP}},
+				StaticServicePorts: map[string]string{"a": "1"},
+			},
+			err: "endpoint a: fixed host ports are only supported for TCP",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateEndpoints(tt.conf)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSessionServiceURLs(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	c := &SimpleComponent{
+		Name: "jaeger",
+		Containers: []SimpleContainerConfig{{
+			Name:         "jaeger",
+			Repository:   "jaegertracing/all-in-one",
+			Tag:          "latest",
+			ServicePorts: map[string]string{"jaeger": "16686"},
+			Endpoints: map[string]Endpoint{
+				"jaeger.otlp-grpc":     {Port: "4317", Scheme: "grpc"},
+				"jaeger.agent-compact": {Port: "6831", Protocol: UDP},
+			},
+		}},
+	}
+	require.NoError(t, sess.StartComponentsContext(context.Background(), c))
+
+	container, err := rt.InspectContainer(context.Background(), "000-jaeger")
+	require.NoError(t, err)
+	udpPort, err := boundHostPort(container, Endpoint{Port: "6831", Protocol: UDP})
+	require.NoError(t, err)
+	addr, err := sess.HostToDockerServiceAddress("jaeger.agent-compact")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:"+udpPort, addr)
+
+	url, err := sess.DockerToDockerServiceURL("jaeger.otlp-grpc")
+	require.NoError(t, err)
+	assert.Equal(t, "grpc://000-jaeger:4317", url)
+	url, err = sess.AliasServiceURL("jaeger.otlp-grpc")
+	require.NoError(t, err)
+	assert.Equal(t, "grpc://jaeger:4317", url)
+	url, err = sess.AutoServiceURL("jaeger.agent-compact")
+	require.NoError(t, err)
+	assert.Equal(t, "udp://localhost:"+udpPort, url)
+	url, err = sess.DockerToDockerServiceURL("jaeger")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://000-jaeger:16686", url)
+
+	_, err = sess.AutoServiceURL("jaeger.ui")
+	assert.EqualError(t, err, `external service address not registered for "jaeger.ui"`)
+
+	fpath := filepath.Join(t.TempDir(), DefaultSessionFile)
+	require.NoError(t, sess.PersistToFile(fpath))
+	loaded, err := LoadSessionFromFile(false, fpath)
+	require.NoError(t, err)
+	e, err := loaded.ServiceEndpoint("jaeger.otlp-grpc")
+	require.NoError(t, err)
+	assert.Equal(t, Endpoint{Port: "4317", Protocol: TCP, Scheme: "grpc"}, e)
+}
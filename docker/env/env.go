@@ -2,39 +2,19 @@
 package env
 
 import (
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/beatlabs/bake/docker"
-	"github.com/beatlabs/bake/internal/sh"
 )
 
-const (
-	inspectEnvFormat = "{{range $index, $new := .Config.Env}}{{$new}}{{println}}{{end}}"
-	dockerCmd        = "docker"
-)
-
-var (
-	skipEnvSet = map[string]bool{
-		"PATH":     true,
-		"HOME":     true,
-		"HOSTNAME": true,
-	}
-
-	inspectEnvArgs = []string{
-		"inspect",
-		"-f",
-		inspectEnvFormat,
-	}
-
-	// inspectFormatEnv in order to make format pass correctly without replacing $ variables.
-	inspectFormatEnv = map[string]string{
-		"new":   "$new",
-		"index": "$index",
-	}
-)
+// skipEnvSet lists the env vars of the container runtime which are not exported.
+var skipEnvSet = map[string]bool{
+	"PATH":     true,
+	"HOME":     true,
+	"HOSTNAME": true,
+}
 
 // GetServiceEnvs inspects docker container envs from given service
 // If service does not exist (docker container should exist, it can be stopped), then it fails because there is no service to debug.
@@ -44,20 +24,23 @@ func GetServiceEnvs(session *docker.Session, serviceName string, extraRules Repl
 		return nil, err
 	}
 
-	env := inspectFormatEnv
-	args := append(inspectEnvArgs, containerName) // nolint:gocritic
-	cmdOut, err := run(env, args)
+	rt, err := session.Runtime()
 	if err != nil {
 		return nil, err
 	}
+	container, err := rt.InspectContainer(context.Background(), containerName)
+	if err != nil {
+		return nil, fmt.Errorf("inspect container %s: %w", containerName, err)
+	}
 
-	envsRaw := strings.Split(cmdOut.String(), "\n")
 	envs := make(map[string]string)
-	for _, envRaw := range envsRaw {
-		envData := strings.SplitN(envRaw, "=", 2)
-		if len(envData) == 2 {
-			if skip := skipEnvSet[envData[0]]; !skip {
-				envs[envData[0]] = envData[1]
+	if container.Config != nil {
+		for _, envRaw := range container.Config.Env {
+			envData := strings.SplitN(envRaw, "=", 2)
+			if len(envData) == 2 {
+				if skip := skipEnvSet[envData[0]]; !skip {
+					envs[envData[0]] = envData[1]
+				}
 			}
 		}
 	}
@@ -74,26 +57,17 @@ func GetServiceEnvs(session *docker.Session, serviceName string, extraRules Repl
 	return envs, nil
 }
 
-// BuildContainerName from session id and service name
-// fails if service is not registered in bake session.
+// BuildContainerName returns the container of a service as recorded in the session, falling back to the session id
+// and service name for sessions which do not record it.
+// Fails if service is not registered in bake session.
 func BuildContainerName(session *docker.Session, serviceName string) (string, error) {
 	_, err := session.AutoServiceAddress(serviceName)
 	if err != nil {
 		return "", fmt.Errorf("service with name %s is not found", serviceName)
 	}
 
-	return fmt.Sprintf("%s-%s", session.ID(), serviceName), nil
-}
-
-func run(env map[string]string, args []string) (bytes.Buffer, error) {
-	var cmdOut, cmdErr bytes.Buffer
-
-	_, err := sh.Exec(env, &cmdOut, &cmdErr, dockerCmd, args...)
-	if err != nil {
-		return cmdOut, err
+	if info, ok := session.ServiceInfo(serviceName); ok && info.Container != "" {
+		return info.Container, nil
 	}
-	if cmdErr.String() != "" {
-		return cmdOut, errors.New(cmdErr.String())
-	}
-	return cmdOut, nil
+	return fmt.Sprintf("%s-%s", session.ID(), serviceName), nil
 }
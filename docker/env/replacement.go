@@ -21,11 +21,13 @@ type ReplacementRuleList []ReplacementRule
 // Replace replaces envs by list.
 func (l ReplacementRuleList) Replace(envs map[string]string) map[string]string {
 	for envName, value := range envs {
+		// Rules apply on top of each other, e.g. to values holding the addresses of several services.
 		for _, r := range l {
 			if r.Supports(envName, value) {
-				envs[envName] = r.Replace(value)
+				value = r.Replace(value)
 			}
 		}
+		envs[envName] = value
 	}
 	return envs
 }
@@ -107,12 +109,13 @@ func (m mongoURIReplacementRule) Replace(in string) string {
 }
 
 // newReplacementRulesList where key is docker related endpoint and new is corresponding localhost endpoint.
+// Network alias endpoints, e.g. "kafka:9092", are replaced as well.
 func newReplacementRulesList(session *docker.Session, serviceName string) (ReplacementRuleList, error) {
 	serviceNames := session.ServiceNames()
 	sort.Strings(serviceNames)
-	replacements := make(ReplacementRuleList, len(serviceNames))
+	replacements := make(ReplacementRuleList, 0, len(serviceNames))
 
-	for i, svc := range serviceNames {
+	for _, svc := range serviceNames {
 		dockerAddress, err := session.DockerToDockerServiceAddress(svc)
 		if err != nil {
 			return nil, err
@@ -121,13 +124,23 @@ func newReplacementRulesList(session *docker.Session, serviceName string) (Repla
 		if err != nil {
 			return nil, err
 		}
-		switch svc {
-		case serviceName:
-			replacements[i] = NewFullReplacementRule("PATRON_HTTP_DEFAULT_PORT", strings.Split(localAddress, ":")[1])
-		case mongodb.ServiceName:
-			replacements[i] = newMongoURIReplacementRule(dockerAddress, localAddress)
-		default:
-			replacements[i] = NewSubstrReplacement(dockerAddress, localAddress)
+		if svc == serviceName {
+			replacements = append(replacements,
+				NewFullReplacementRule("PATRON_HTTP_DEFAULT_PORT", strings.Split(localAddress, ":")[1]))
+			continue
+		}
+
+		dockerAddresses := []string{dockerAddress}
+		// The session ID prefixed address goes first, as the alias address is a suffix of it.
+		if aliasAddress, err := session.AliasServiceAddress(svc); err == nil && aliasAddress != dockerAddress {
+			dockerAddresses = append(dockerAddresses, aliasAddress)
+		}
+		for _, addr := range dockerAddresses {
+			if svc == mongodb.ServiceName {
+				replacements = append(replacements, newMongoURIReplacementRule(addr, localAddress))
+			} else {
+				replacements = append(replacements, NewSubstrReplacement(addr, localAddress))
+			}
 		}
 	}
 
@@ -70,6 +70,26 @@ func TestReplacement_SubstrRule(t *testing.T) {
 	}
 }
 
+func TestReplacementRuleList_Replace(t *testing.T) {
+	t.Parallel()
+
+	rules := ReplacementRuleList{
+		NewSubstrReplacement("000-kafka:9092", "localhost:64949"),
+		NewSubstrReplacement("kafka:9092", "localhost:64949"),
+		NewSubstrReplacement("000-zookeeper:2181", "localhost:64951"),
+	}
+	envs := rules.Replace(map[string]string{
+		"TEST_KAFKA_BROKERS": "000-kafka:9092",
+		"TEST_KAFKA_ALIAS":   "kafka:9092",
+		"TEST_ADDRESSES":     "000-kafka:9092,000-zookeeper:2181",
+	})
+	assert.Equal(t, map[string]string{
+		"TEST_KAFKA_BROKERS": "localhost:64949",
+		"TEST_KAFKA_ALIAS":   "localhost:64949",
+		"TEST_ADDRESSES":     "localhost:64949,localhost:64951",
+	}, envs)
+}
+
 func TestReplacement_MongoUriRule(t *testing.T) {
 	t.Parallel()
 
@@ -175,6 +195,16 @@ func TestNewReplacementList(t *testing.T) {
 				&SubstrReplacementRule{old: "000-zookeeper:2181", change: "localhost:64951"},
 			},
 		},
+		"aliases": {
+			sessionFile: "./testdata/aliases.json",
+			expList: ReplacementRuleList{
+				&SubstrReplacementRule{old: "000-kafka:9092", change: "localhost:64949"},
+				&SubstrReplacementRule{old: "kafka:9092", change: "localhost:64949"},
+				&mongoURIReplacementRule{SubstrReplacementRule{old: "000-mongo:27017", change: "localhost:64952"}},
+				&mongoURIReplacementRule{SubstrReplacementRule{old: "mongo:27017", change: "localhost:64952"}},
+				&FullReplacementRule{envName: "PATRON_HTTP_DEFAULT_PORT", new: "65071"},
+			},
+		},
 		"empty": {
 			sessionFile: "./testdata/empty.json",
 			expList:     ReplacementRuleList{},
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ContainerExitedError is returned when a container exits before it is ready.
+type ContainerExitedError struct {
+	Container string
+	ExitCode  int
+	OOMKilled bool
+	// Logs holds the last FailureLogTail log lines of the container.
+	Logs string
+}
+
+func (e *ContainerExitedError) Error() string {
+	msg := fmt.Sprintf("container %s exited with code %d before it was ready", e.Container, e.ExitCode)
+	if e.OOMKilled {
+		msg += " (OOM killed)"
+	}
+	if e.Logs == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s, last log lines:\n%s", msg, e.Logs)
+}
+
+// waitReadyOrExit runs the readiness checks of a container while watching it, and aborts them as soon as the
+// container exits.
+func waitReadyOrExit(ctx context.Context, rt Runtime, session *Session, conf SimpleContainerConfig,
+	containerID string,
+) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	go func() {
+		if err := watchExit(ctx, rt, containerID); err != nil {
+			cancel(err)
+		}
+	}()
+
+	err := waitReady(ctx, session, conf)
+	if err == nil {
+		return nil
+	}
+	var exited *ContainerExitedError
+	if errors.As(context.Cause(ctx), &exited) {
+		return exited
+	}
+	return &readinessError{err: err}
+}
+
+// watchExit blocks until the container exits and describes how, or returns nil once the context is done.
+func watchExit(ctx context.Context, rt Runtime, containerID string) error {
+	code, err := rt.WaitContainer(ctx, containerID)
+	if err != nil || ctx.Err() != nil {
+		// Readiness checks report the container as not ready if it can not be watched.
+		return nil
+	}
+
+	exited := &ContainerExitedError{Container: containerID, ExitCode: code}
+	if c, err := rt.InspectContainer(ctx, containerID); err == nil {
+		exited.Container = strings.TrimPrefix(c.Name, "/")
+		exited.OOMKilled = c.State.OOMKilled
+	}
+	var logs bytes.Buffer
+	if err := rt.ContainerLogs(ctx, containerID, &logs, LogOptions{Tail: FailureLogTail}); err == nil {
+		exited.Logs = strings.TrimRight(logs.String(), "\n")
+	}
+	return exited
+}
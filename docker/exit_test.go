@@ -0,0 +1,16 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerExitedError(t *testing.T) {
+	err := &ContainerExitedError{Container: "000-mongo", ExitCode: 137, OOMKilled: true, Logs: "starting\nkilled"}
+	assert.EqualError(t, err, "container 000-mongo exited with code 137 before it was ready (OOM killed), "+
+		"last log lines:\nstarting\nkilled")
+
+	err = &ContainerExitedError{Container: "000-redis", ExitCode: 1}
+	assert.EqualError(t, err, "container 000-redis exited with code 1 before it was ready")
+}
@@ -0,0 +1,585 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// fakeFirstHostPort is the first host port a FakeRuntime assigns, the start of Docker's ephemeral range.
+const fakeFirstHostPort = 32768
+
+// FakeRuntime is an in-memory Runtime to unit test components, the session registry and the env helpers without
+// Docker. Containers run as soon as they are created, until they are removed or exited with ExitContainer, and
+// containers with a healthcheck are healthy. Images are present once pulled or added with AddImage.
+type FakeRuntime struct {
+	// ExecFunc runs the commands executed in containers. Commands exit with code zero when it is nil.
+	ExecFunc func(c *docker.Container, cmd []string, stdout, stderr io.Writer) int
+
+	mu         sync.Mutex
+	lastID     int
+	lastPort   int
+	containers map[string]*fakeContainer
+	networks   map[string]*docker.Network
+	volumes    map[string]*docker.Volume
+	// images holds the labels of the present images by reference.
+	images map[string]map[string]string
+}
+
+type fakeContainer struct {
+	container *docker.Container
+	logs      bytes.Buffer
+	done      chan struct{}
+	removed   bool
+}
+
+// NewFakeRuntime returns an empty FakeRuntime.
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		lastPort:   fakeFirstHostPort - 1,
+		containers: map[string]*fakeContainer{},
+		networks:   map[string]*docker.Network{},
+		volumes:    map[string]*docker.Volume{},
+		images:     map[string]map[string]string{},
+	}
+}
+
+// AddImage makes images present, as if they were pulled or built.
+func (r *FakeRuntime) AddImage(refs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ref := range refs {
+		r.images[ref] = map[string]string{}
+	}
+}
+
+// AddLabeledImage makes an image present with labels, as if it was built.
+func (r *FakeRuntime) AddLabeledImage(ref string, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.images[ref] = labels
+}
+
+// WriteLogs appends to the logs of a container.
+func (r *FakeRuntime) WriteLogs(id, logs string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fc, err := r.container(id)
+	if err != nil {
+		return err
+	}
+	fc.logs.WriteString(logs)
+	return nil
+}
+
+// ExitContainer stops a running container with the exit code.
+func (r *FakeRuntime) ExitContainer(id string, exitCode int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fc, err := r.container(id)
+	if err != nil {
+		return err
+	}
+	if !fc.container.State.Running {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	fc.container.State.Running = false
+	fc.container.State.Status = "exited"
+	fc.container.State.ExitCode = exitCode
+	fc.container.State.FinishedAt = time.Now()
+	close(fc.done)
+	return nil
+}
+
+// SetHealth sets the health status of a container, e.g. "unhealthy".
+func (r *FakeRuntime) SetHealth(id, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fc, err := r.container(id)
+	if err != nil {
+		return err
+	}
+	fc.container.State.Health.Status = status
+	return nil
+}
+
+// RunContainer creates a running container. The image and the networks must exist, and published host ports must
+// be free.
+func (r *FakeRuntime) RunContainer(_ context.Context, opts docker.CreateContainerOptions) (*docker.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if opts.Config == nil {
+		return nil, fmt.Errorf("container %s has no config", opts.Name)
+	}
+	if _, err := r.container(opts.Name); err == nil {
+		return nil, docker.ErrContainerAlreadyExists
+	}
+	if _, ok := r.images[opts.Config.Image]; !ok {
+		return nil, docker.ErrNoSuchImage
+	}
+	hostConfig := opts.HostConfig
+	if hostConfig == nil {
+		hostConfig = &docker.HostConfig{}
+	}
+
+	r.lastID++
+	c := &docker.Container{
+		ID:         fmt.Sprintf("%064x", r.lastID),
+		Name:       "/" + opts.Name,
+		Created:    time.Now(),
+		Image:      imageID(opts.Config.Image),
+		Config:     opts.Config,
+		HostConfig: hostConfig,
+		State: docker.State{
+			Status:    "running",
+			Running:   true,
+			StartedAt: time.Now(),
+		},
+		NetworkSettings: &docker.NetworkSettings{
+			Networks: map[string]docker.ContainerNetwork{},
+			Ports:    map[docker.Port][]docker.PortBinding{},
+		},
+	}
+	if hc := opts.Config.Healthcheck; hc != nil && (len(hc.Test) == 0 || hc.Test[0] != "NONE") {
+		c.State.Health.Status = "healthy"
+	}
+
+	if opts.NetworkingConfig != nil {
+		for id, endpoint := range opts.NetworkingConfig.EndpointsConfig {
+			n, err := r.network(id)
+			if err != nil {
+				return nil, err
+			}
+			var aliases []string
+			if endpoint != nil {
+				aliases = endpoint.Aliases
+			}
+			c.NetworkSettings.Networks[n.Name] = docker.ContainerNetwork{
+				NetworkID: n.ID,
+				Aliases:   aliases,
+				IPAddress: fmt.Sprintf("172.18.%d.%d", r.lastID/250, r.lastID%250+2),
+			}
+		}
+	}
+
+	if err := r.bindPorts(c, opts.Config, hostConfig); err != nil {
+		return nil, err
+	}
+
+	r.containers[c.ID] = &fakeContainer{container: c, done: make(chan struct{})}
+	return copyContainer(c), nil
+}
+
+func (r *FakeRuntime) bindPorts(c *docker.Container, config *docker.Config, hostConfig *docker.HostConfig) error {
+	used := map[string]bool{}
+	for _, fc := range r.containers {
+		if !fc.container.State.Running {
+			continue
+		}
+		for _, bindings := range fc.container.NetworkSettings.Ports {
+			for _, b := range bindings {
+				used[b.HostPort] = true
+			}
+		}
+	}
+
+	for port, bindings := range hostConfig.PortBindings {
+		for _, b := range bindings {
+			hostPort := b.HostPort
+			if hostPort == "" {
+				hostPort = r.freePort(used)
+			} else if used[hostPort] {
+				return fmt.Errorf("bind for 0.0.0.0:%s failed: port is already allocated", hostPort)
+			}
+			used[hostPort] = true
+			c.NetworkSettings.Ports[port] = append(c.NetworkSettings.Ports[port],
+				docker.PortBinding{HostIP: "0.0.0.0", HostPort: hostPort})
+		}
+	}
+	if !hostConfig.PublishAllPorts {
+		return nil
+	}
+	for port := range config.ExposedPorts {
+		if _, ok := c.NetworkSettings.Ports[port]; ok {
+			continue
+		}
+		hostPort := r.freePort(used)
+		used[hostPort] = true
+		c.NetworkSettings.Ports[port] = []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPort}}
+	}
+	return nil
+}
+
+func (r *FakeRuntime) freePort(used map[string]bool) string {
+	for {
+		r.lastPort++
+		if port := strconv.Itoa(r.lastPort); !used[port] {
+			return port
+		}
+	}
+}
+
+// InspectContainer returns a container by ID or name.
+func (r *FakeRuntime) InspectContainer(_ context.Context, id string) (*docker.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fc, err := r.container(id)
+	if err != nil {
+		return nil, err
+	}
+	return copyContainer(fc.container), nil
+}
+
+// ListContainers lists the containers matching the filters. The label, name and id filters are supported.
+func (r *FakeRuntime) ListContainers(_ context.Context, filters map[string][]string,
+) ([]docker.APIContainers, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var containers []docker.APIContainers
+	for _, fc := range r.containers {
+		c := fc.container
+		if !matchesFilters(filters, c.ID, strings.TrimPrefix(c.Name, "/"), c.Config.Labels) {
+			continue
+		}
+		containers = append(containers, docker.APIContainers{
+			ID:      c.ID,
+			Names:   []string{c.Name},
+			Image:   c.Config.Image,
+			Created: c.Created.Unix(),
+			State:   c.State.Status,
+			Status:  c.State.String(),
+			Labels:  c.Config.Labels,
+		})
+	}
+	return containers, nil
+}
+
+// WaitContainer blocks until a container exits and returns its exit code.
+func (r *FakeRuntime) WaitContainer(ctx context.Context, id string) (int, error) {
+	r.mu.Lock()
+	fc, err := r.container(id)
+	r.mu.Unlock()
+	if err != nil {
+		return -1, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case <-fc.done:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fc.removed {
+		return -1, &docker.NoSuchContainer{ID: id}
+	}
+	return fc.container.State.ExitCode, nil
+}
+
+// ExecContainer runs a command in a running container through ExecFunc.
+func (r *FakeRuntime) ExecContainer(_ context.Context, id string, cmd []string, stdout, stderr io.Writer,
+) (int, error) {
+	r.mu.Lock()
+	fc, err := r.container(id)
+	var c *docker.Container
+	if err == nil {
+		c = copyContainer(fc.container)
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return -1, err
+	}
+	if !c.State.Running {
+		return -1, fmt.Errorf("container %s is not running", id)
+	}
+
+	if r.ExecFunc == nil {
+		return 0, nil
+	}
+	return r.ExecFunc(c, cmd, stdout, stderr), nil
+}
+
+// ContainerLogs writes the logs of a container, Tail is honored.
+func (r *FakeRuntime) ContainerLogs(_ context.Context, id string, w io.Writer, opts LogOptions) error {
+	r.mu.Lock()
+	fc, err := r.container(id)
+	var logs string
+	if err == nil {
+		logs = fc.logs.String()
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if opts.Tail > 0 {
+		lines := strings.SplitAfter(strings.TrimSuffix(logs, "\n"), "\n")
+		if len(lines) > opts.Tail {
+			lines = lines[len(lines)-opts.Tail:]
+		}
+		logs = strings.Join(lines, "")
+		if logs != "" && !strings.HasSuffix(logs, "\n") {
+			logs += "\n"
+		}
+	}
+	_, err = io.WriteString(w, logs)
+	return err
+}
+
+// RemoveContainer removes a container, missing containers are not considered an error.
+func (r *FakeRuntime) RemoveContainer(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fc, err := r.container(id)
+	if err != nil {
+		return nil
+	}
+	delete(r.containers, fc.container.ID)
+	fc.removed = true
+	if fc.container.State.Running {
+		close(fc.done)
+	}
+	return nil
+}
+
+// InspectImage returns an image which is present, along with a repository digest, or docker.ErrNoSuchImage.
+func (r *FakeRuntime) InspectImage(_ context.Context, ref string) (*docker.Image, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.images[ref]; !ok {
+		return nil, docker.ErrNoSuchImage
+	}
+	repository := ref
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		repository = ref[:i]
+	} else if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repository = ref[:i]
+	}
+	return &docker.Image{
+		ID:          imageID(ref),
+		RepoTags:    []string{ref},
+		RepoDigests: []string{repository + "@" + imageID(ref)},
+	}, nil
+}
+
+// PullImage makes an image present.
+func (r *FakeRuntime) PullImage(_ context.Context, img ImageRef) error {
+	r.AddImage(img.Reference())
+	return nil
+}
+
+// ListImages lists the present images matching the filters. The label filter is supported.
+func (r *FakeRuntime) ListImages(_ context.Context, filters map[string][]string) ([]docker.APIImages, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var images []docker.APIImages
+	for ref, labels := range r.images {
+		if matchesFilters(filters, imageID(ref), ref, labels) {
+			images = append(images, docker.APIImages{ID: imageID(ref), RepoTags: []string{ref}, Labels: labels})
+		}
+	}
+	return images, nil
+}
+
+// RemoveImage removes an image by ID or reference, missing images are not considered an error.
+func (r *FakeRuntime) RemoveImage(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ref := range r.images {
+		if ref == id || imageID(ref) == id {
+			delete(r.images, ref)
+		}
+	}
+	return nil
+}
+
+// CreateNetwork creates a network, network names are unique.
+func (r *FakeRuntime) CreateNetwork(_ context.Context, name string, labels map[string]string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.network(name); err == nil {
+		return "", fmt.Errorf("network with name %s already exists", name)
+	}
+	r.lastID++
+	n := &docker.Network{ID: fmt.Sprintf("%064x", r.lastID), Name: name, Driver: "bridge", Labels: labels}
+	r.networks[n.ID] = n
+	return n.ID, nil
+}
+
+// InspectNetwork returns a network by ID or name.
+func (r *FakeRuntime) InspectNetwork(_ context.Context, id string) (*docker.Network, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n, err := r.network(id)
+	if err != nil {
+		return nil, err
+	}
+	inspected := *n
+	return &inspected, nil
+}
+
+// ListNetworks lists the networks matching the filters. The label, name and id filters are supported.
+func (r *FakeRuntime) ListNetworks(_ context.Context, filters map[string][]string) ([]docker.Network, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var networks []docker.Network
+	for _, n := range r.networks {
+		if matchesFilters(filters, n.ID, n.Name, n.Labels) {
+			networks = append(networks, *n)
+		}
+	}
+	return networks, nil
+}
+
+// RemoveNetwork removes a network without containers, missing networks are not considered an error.
+func (r *FakeRuntime) RemoveNetwork(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n, err := r.network(id)
+	if err != nil {
+		return nil
+	}
+	for _, fc := range r.containers {
+		if _, ok := fc.container.NetworkSettings.Networks[n.Name]; ok {
+			return fmt.Errorf("network %s has active endpoints", n.Name)
+		}
+	}
+	delete(r.networks, n.ID)
+	return nil
+}
+
+// InspectVolume returns a volume by name, or docker.ErrNoSuchVolume.
+func (r *FakeRuntime) InspectVolume(_ context.Context, name string) (*docker.Volume, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.volumes[name]
+	if !ok {
+		return nil, docker.ErrNoSuchVolume
+	}
+	inspected := *v
+	return &inspected, nil
+}
+
+// CreateVolume creates a volume, volume names are unique.
+func (r *FakeRuntime) CreateVolume(_ context.Context, name string, labels map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.volumes[name]; ok {
+		return fmt.Errorf("volume %s already exists", name)
+	}
+	r.volumes[name] = &docker.Volume{Name: name, Driver: "local", Labels: labels}
+	return nil
+}
+
+// ListVolumes lists the volumes matching the filters. The label and name filters are supported.
+func (r *FakeRuntime) ListVolumes(_ context.Context, filters map[string][]string) ([]docker.Volume, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var volumes []docker.Volume
+	for _, v := range r.volumes {
+		if matchesFilters(filters, v.Name, v.Name, v.Labels) {
+			volumes = append(volumes, *v)
+		}
+	}
+	return volumes, nil
+}
+
+// RemoveVolume removes a volume, missing volumes are not considered an error.
+func (r *FakeRuntime) RemoveVolume(_ context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.volumes, name)
+	return nil
+}
+
+// container finds a container by ID or name, the lock must be held.
+func (r *FakeRuntime) container(id string) (*fakeContainer, error) {
+	if fc, ok := r.containers[id]; ok {
+		return fc, nil
+	}
+	for _, fc := range r.containers {
+		if fc.container.Name == "/"+strings.TrimPrefix(id, "/") {
+			return fc, nil
+		}
+	}
+	return nil, &docker.NoSuchContainer{ID: id}
+}
+
+// network finds a network by ID or name, the lock must be held.
+func (r *FakeRuntime) network(id string) (*docker.Network, error) {
+	if n, ok := r.networks[id]; ok {
+		return n, nil
+	}
+	for _, n := range r.networks {
+		if n.Name == id {
+			return n, nil
+		}
+	}
+	return nil, &docker.NoSuchNetwork{ID: id}
+}
+
+func copyContainer(c *docker.Container) *docker.Container {
+	copied := *c
+	return &copied
+}
+
+// imageID derives a stable image ID from an image reference.
+func imageID(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// matchesFilters applies Docker API style label, name and id filters.
+func matchesFilters(filters map[string][]string, id, name string, labels map[string]string) bool {
+	for key, values := range filters {
+		for _, v := range values {
+			switch key {
+			case "label":
+				k, want, hasValue := strings.Cut(v, "=")
+				got, ok := labels[k]
+				if !ok || (hasValue && got != want) {
+					return false
+				}
+			case "name":
+				if !strings.Contains(name, v) {
+					return false
+				}
+			case "id":
+				if !strings.HasPrefix(id, v) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Runtime = (*FakeRuntime)(nil)
+
+func runFakeContainer(t *testing.T, rt *FakeRuntime, name string, hc *docker.HostConfig) (*docker.Container, error) {
+	t.Helper()
+
+	rt.AddImage("alpine:latest")
+	return rt.RunContainer(context.Background(), docker.CreateContainerOptions{
+		Name: name,
+		Config: &docker.Config{
+			Image:        "alpine:latest",
+			ExposedPorts: map[docker.Port]struct{}{"80/tcp": {}},
+			Labels:       resourceLabels("000", "web"),
+		},
+		HostConfig: hc,
+	})
+}
+
+func TestFakeRuntimeRunContainer(t *testing.T) {
+	rt := NewFakeRuntime()
+	ctx := context.Background()
+
+	_, err := rt.RunContainer(ctx, docker.CreateContainerOptions{Name: "web", Config: &docker.Config{Image: "nginx"}})
+	require.ErrorIs(t, err, docker.ErrNoSuchImage)
+
+	c, err := runFakeContainer(t, rt, "web", &docker.HostConfig{PublishAllPorts: true})
+	require.NoError(t, err)
+	assert.Equal(t, "/web", c.Name)
+	assert.True(t, c.State.Running)
+	assert.Equal(t, []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: "32768"}}, c.NetworkSettings.Ports["80/tcp"])
+
+	_, err = runFakeContainer(t, rt, "web", nil)
+	require.ErrorIs(t, err, docker.ErrContainerAlreadyExists)
+
+	_, err = runFakeContainer(t, rt, "other", &docker.HostConfig{
+		PortBindings: map[docker.Port][]docker.PortBinding{"80/tcp": {{HostPort: "32768"}}},
+	})
+	require.Error(t, err)
+	assert.True(t, isPortConflict(err))
+}
+
+func TestFakeRuntimeListContainers(t *testing.T) {
+	rt := NewFakeRuntime()
+	ctx := context.Background()
+	_, err := runFakeContainer(t, rt, "000-web", nil)
+	require.NoError(t, err)
+
+	containers, err := rt.ListContainers(ctx, sessionFilter("000"))
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, "000-web", containerName(containers[0]))
+	assert.Equal(t, "running", containers[0].State)
+
+	containers, err = rt.ListContainers(ctx, sessionFilter("001"))
+	require.NoError(t, err)
+	assert.Empty(t, containers)
+}
+
+func TestFakeRuntimeWaitContainer(t *testing.T) {
+	rt := NewFakeRuntime()
+	ctx := context.Background()
+	_, err := runFakeContainer(t, rt, "web", nil)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, rt.ExitContainer("web", 2))
+	}()
+	code, err := rt.WaitContainer(ctx, "web")
+	require.NoError(t, err)
+	assert.Equal(t, 2, code)
+
+	_, err = rt.ExecContainer(ctx, "web", []string{"true"}, nil, nil)
+	require.EqualError(t, err, "container web is not running")
+
+	require.NoError(t, rt.RemoveContainer(ctx, "web"))
+	require.NoError(t, rt.RemoveContainer(ctx, "web"))
+}
+
+func TestFakeRuntimeContainerLogs(t *testing.T) {
+	rt := NewFakeRuntime()
+	_, err := runFakeContainer(t, rt, "web", nil)
+	require.NoError(t, err)
+	require.NoError(t, rt.WriteLogs("web", "one\ntwo\nthree\n"))
+
+	var buf bytes.Buffer
+	require.NoError(t, rt.ContainerLogs(context.Background(), "web", &buf, LogOptions{Tail: 2}))
+	assert.Equal(t, "two\nthree\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, rt.ContainerLogs(context.Background(), "web", &buf, LogOptions{}))
+	assert.Equal(t, "one\ntwo\nthree\n", buf.String())
+}
+
+func TestFakeRuntimeNetworks(t *testing.T) {
+	rt := NewFakeRuntime()
+	ctx := context.Background()
+
+	id, err := rt.CreateNetwork(ctx, "000", resourceLabels("000", ""))
+	require.NoError(t, err)
+	_, err = rt.CreateNetwork(ctx, "000", nil)
+	require.EqualError(t, err, "network with name 000 already exists")
+
+	networks, err := rt.ListNetworks(ctx, sessionFilter("000"))
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+	assert.Equal(t, id, networks[0].ID)
+
+	rt.AddImage("alpine:latest")
+	_, err = rt.RunContainer(ctx, docker.CreateContainerOptions{
+		Name:             "web",
+		Config:           &docker.Config{Image: "alpine:latest"},
+		NetworkingConfig: &docker.NetworkingConfig{EndpointsConfig: map[string]*docker.EndpointConfig{id: {}}},
+	})
+	require.NoError(t, err)
+	require.EqualError(t, rt.RemoveNetwork(ctx, id), "network 000 has active endpoints")
+
+	require.NoError(t, rt.RemoveContainer(ctx, "web"))
+	require.NoError(t, rt.RemoveNetwork(ctx, id))
+	_, err = rt.InspectNetwork(ctx, id)
+	var noSuchNetwork *docker.NoSuchNetwork
+	assert.ErrorAs(t, err, &noSuchNetwork)
+}
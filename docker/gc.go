@@ -0,0 +1,179 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// GCOptions configures GarbageCollect.
+type GCOptions struct {
+	// TTL is the age after which a session is considered stale, based on its most recently created resource.
+	TTL time.Duration
+	// DryRun only lists the stale sessions without removing anything.
+	DryRun bool
+	// Endpoint is the Docker daemon to collect, e.g. "unix:///run/user/1000/podman/podman.sock". It defaults to the
+	// daemon of DOCKER_HOST or the local one, as for sessions.
+	Endpoint string
+	// Runtime collects the resources of another runtime than Docker, e.g. a FakeRuntime. Endpoint is then ignored.
+	Runtime Runtime
+}
+
+// StaleSession groups the bake resources of a session whose most recent resource is older than the TTL.
+type StaleSession struct {
+	ID          string
+	LastCreated time.Time
+	Resources   CleanupReport
+
+	resources []labeledResource
+}
+
+// String renders the stale session as a human readable summary.
+func (s StaleSession) String() string {
+	return fmt.Sprintf("Session %q, last created at %s\n%s", s.ID, s.LastCreated.Format(time.RFC3339), s.Resources)
+}
+
+type labeledResource struct {
+	kind    resourceKind
+	id      string
+	name    string
+	labels  map[string]string
+	created time.Time
+}
+
+// createdAt prefers the bake creation label over the creation time reported by Docker, if any.
+func (r labeledResource) createdAt() time.Time {
+	if t, err := time.Parse(time.RFC3339, r.labels[LabelCreated]); err == nil {
+		return t
+	}
+	return r.created
+}
+
+// GarbageCollect finds the bake sessions across the whole Docker daemon whose resources are all older than the TTL,
+// and removes them unless running in dry-run mode. Sessions are returned sorted by ID.
+func GarbageCollect(ctx context.Context, opts GCOptions) ([]StaleSession, error) {
+	rt := opts.Runtime
+	if rt == nil {
+		client, err := newDockerClient(opts.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		rt = NewDockerRuntime(client)
+	}
+
+	resources, err := listBakeResources(ctx, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := groupStaleSessions(resources, time.Now(), opts.TTL)
+	if opts.DryRun {
+		return stale, nil
+	}
+
+	for _, s := range stale {
+		for _, kind := range []resourceKind{containerResource, imageResource, volumeResource, networkResource} {
+			for _, r := range s.resources {
+				if r.kind != kind {
+					continue
+				}
+				err := removeResource(ctx, rt, trackedResource{kind: r.kind, id: r.id})
+				if err != nil {
+					return stale, fmt.Errorf("session %q: remove %s %s: %w", s.ID, r.kind, r.name, err)
+				}
+			}
+		}
+	}
+
+	return stale, nil
+}
+
+// listBakeResources lists every container, image, volume and network carrying the bake session label.
+func listBakeResources(ctx context.Context, rt Runtime) ([]labeledResource, error) {
+	filter := map[string][]string{"label": {LabelSession}}
+	var resources []labeledResource
+
+	containers, err := rt.ListContainers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		resources = append(resources, labeledResource{
+			kind: containerResource, id: c.ID, name: containerName(c), labels: c.Labels, created: time.Unix(c.Created, 0),
+		})
+	}
+
+	images, err := rt.ListImages(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range images {
+		name := img.ID
+		if len(img.RepoTags) > 0 {
+			name = img.RepoTags[0]
+		}
+		resources = append(resources, labeledResource{
+			kind: imageResource, id: img.ID, name: name, labels: img.Labels, created: time.Unix(img.Created, 0),
+		})
+	}
+
+	volumes, err := rt.ListVolumes(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		resources = append(resources, labeledResource{kind: volumeResource, id: v.Name, name: v.Name, labels: v.Labels})
+	}
+
+	networks, err := rt.ListNetworks(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		resources = append(resources, labeledResource{kind: networkResource, id: n.ID, name: n.Name, labels: n.Labels})
+	}
+
+	return resources, nil
+}
+
+// groupStaleSessions groups resources by session and keeps the sessions whose most recent resource is older than
+// the TTL. Sessions without any known creation time are skipped.
+func groupStaleSessions(resources []labeledResource, now time.Time, ttl time.Duration) []StaleSession {
+	sessions := map[string]*StaleSession{}
+	for _, r := range resources {
+		id := r.labels[LabelSession]
+		s, ok := sessions[id]
+		if !ok {
+			s = &StaleSession{ID: id}
+			sessions[id] = s
+		}
+		s.resources = append(s.resources, r)
+		if created := r.createdAt(); created.After(s.LastCreated) {
+			s.LastCreated = created
+		}
+	}
+
+	stale := make([]StaleSession, 0, len(sessions))
+	for _, s := range sessions {
+		if s.LastCreated.IsZero() || now.Sub(s.LastCreated) < ttl {
+			continue
+		}
+		for _, r := range s.resources {
+			switch r.kind {
+			case containerResource:
+				s.Resources.Containers = append(s.Resources.Containers, r.name)
+			case imageResource:
+				s.Resources.Images = append(s.Resources.Images, r.name)
+			case volumeResource:
+				s.Resources.Volumes = append(s.Resources.Volumes, r.name)
+			case networkResource:
+				s.Resources.Networks = append(s.Resources.Networks, r.name)
+			}
+		}
+		stale = append(stale, *s)
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i].ID < stale[j].ID })
+	return stale
+}
@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupStaleSessions(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+
+	labels := func(session string, created time.Time) map[string]string {
+		return map[string]string{LabelSession: session, LabelCreated: created.Format(time.RFC3339)}
+	}
+
+	resources := []labeledResource{
+		{kind: containerResource, id: "c1", name: "abc-redis", labels: labels("abc", old)},
+		{kind: imageResource, id: "i1", name: "testservice:abc", labels: labels("abc", old)},
+		{kind: networkResource, id: "n1", name: "abc", labels: map[string]string{LabelSession: "abc"}},
+		{kind: containerResource, id: "c2", name: "def-redis", labels: labels("def", old)},
+		{kind: containerResource, id: "c3", name: "def-mongo", labels: labels("def", recent)},
+		{kind: containerResource, id: "c4", name: "ghi-redis", labels: map[string]string{LabelSession: "ghi"}, created: old},
+		{kind: volumeResource, id: "v1", name: "jkl-data", labels: map[string]string{LabelSession: "jkl"}},
+	}
+
+	stale := groupStaleSessions(resources, now, 24*time.Hour)
+	require.Len(t, stale, 2)
+
+	assert.Equal(t, "abc", stale[0].ID)
+	assert.Equal(t, old, stale[0].LastCreated)
+	assert.Equal(t, CleanupReport{
+		Containers: []string{"abc-redis"},
+		Images:     []string{"testservice:abc"},
+		Networks:   []string{"abc"},
+	}, stale[0].Resources)
+
+	assert.Equal(t, "ghi", stale[1].ID)
+	assert.Equal(t, []string{"ghi-redis"}, stale[1].Resources.Containers)
+}
+
+func TestGarbageCollectRuntime(t *testing.T) {
+	ctx := context.Background()
+	rt := NewFakeRuntime()
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	labels := func(session, created string) map[string]string {
+		return map[string]string{LabelSession: session, LabelCreated: created}
+	}
+
+	staleNetworkID, err := rt.CreateNetwork(ctx, "abc", labels("abc", old))
+	require.NoError(t, err)
+	require.NoError(t, rt.CreateVolume(ctx, "abc-data", labels("abc", old)))
+	rt.AddLabeledImage("service:0123456789abcdef", labels("abc", old))
+	_, err = rt.CreateNetwork(ctx, "def", labels("def", time.Now().UTC().Format(time.RFC3339)))
+	require.NoError(t, err)
+
+	stale, err := GarbageCollect(ctx, GCOptions{TTL: 24 * time.Hour, DryRun: true, Runtime: rt})
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+	assert.Equal(t, "abc", stale[0].ID)
+	networks, err := rt.ListNetworks(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, networks, 2)
+
+	stale, err = GarbageCollect(ctx, GCOptions{TTL: 24 * time.Hour, Runtime: rt})
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+	assert.Equal(t, CleanupReport{
+		Images:   []string{"service:0123456789abcdef"},
+		Volumes:  []string{"abc-data"},
+		Networks: []string{"abc"},
+	}, stale[0].Resources)
+
+	_, err = rt.InspectNetwork(ctx, staleNetworkID)
+	require.Error(t, err)
+	networks, err = rt.ListNetworks(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+	assert.Equal(t, "def", networks[0].Name)
+	volumes, err := rt.ListVolumes(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, volumes)
+	images, err := rt.ListImages(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, images)
+}
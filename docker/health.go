@@ -0,0 +1,351 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// HealthCheckTimeout bounds the readiness check of each component while validating a session.
+var HealthCheckTimeout = 30 * time.Second
+
+// HealthPolicy decides how unhealthy services of a loaded session are handled.
+type HealthPolicy int
+
+const (
+	// FailUnhealthy returns an UnhealthySessionError describing every unhealthy service.
+	FailUnhealthy HealthPolicy = iota
+	// RestartUnhealthy removes and starts again the components owning unhealthy services.
+	RestartUnhealthy
+	// RecreateSession removes every container of the session and starts all components again.
+	RecreateSession
+)
+
+// ReadinessChecker is implemented by components which can check whether their started containers are ready.
+type ReadinessChecker interface {
+	CheckReady(context.Context, *Session) error
+}
+
+// ServiceHealth describes the health of a registered service.
+type ServiceHealth struct {
+	Service   string
+	Component string
+	Container string
+	// State is the Docker state of the container, or "missing" if there is no such container.
+	State string
+	// Err is set when the service is unhealthy.
+	Err error
+}
+
+// HealthReport describes the health of every registered service of a session.
+type HealthReport struct {
+	Services []ServiceHealth
+}
+
+// Healthy reports whether all services are healthy.
+func (r HealthReport) Healthy() bool {
+	return len(r.Unhealthy()) == 0
+}
+
+// Unhealthy lists the unhealthy services.
+func (r HealthReport) Unhealthy() []ServiceHealth {
+	var unhealthy []ServiceHealth
+	for _, s := range r.Services {
+		if s.Err != nil {
+			unhealthy = append(unhealthy, s)
+		}
+	}
+	return unhealthy
+}
+
+// String renders the report as a human readable summary.
+func (r HealthReport) String() string {
+	var b strings.Builder
+	for _, s := range r.Services {
+		status := "healthy"
+		if s.Err != nil {
+			status = s.Err.Error()
+		}
+		fmt.Fprintf(&b, "%s (container %q, state %s): %s\n", s.Service, s.Container, s.State, status)
+	}
+	return b.String()
+}
+
+// UnhealthySessionError is returned when a session has unhealthy services.
+type UnhealthySessionError struct {
+	Report HealthReport
+}
+
+func (e *UnhealthySessionError) Error() string {
+	unhealthy := e.Report.Unhealthy()
+	names := make([]string, 0, len(unhealthy))
+	for _, s := range unhealthy {
+		names = append(names, s.Service)
+	}
+	return fmt.Sprintf("session has %d unhealthy services: %s\n%s",
+		len(unhealthy), strings.Join(names, ", "), e.Report)
+}
+
+// LoadHealthySession loads a session from a file, validates it against the components which started it and applies
+// the policy to unhealthy services. If the session was repaired it is persisted back to the file.
+// The session options are applied to the loaded session, e.g. WithRuntime.
+func LoadHealthySession(ctx context.Context, fpath string, policy HealthPolicy, cs []ContextComponent,
+	opts ...SessionOptionFunc,
+) (*Session, error) {
+	s, err := LoadSessionFromFile(InDocker(), fpath, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	repaired, err := s.EnsureHealthy(ctx, policy, cs...)
+	if err != nil {
+		return nil, err
+	}
+	if repaired {
+		if err := s.PersistToFile(fpath); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Validate checks that every registered service has an existing, running container and that the components passed
+// in, which implement ReadinessChecker, are ready.
+func (s *Session) Validate(ctx context.Context, cs ...ContextComponent) (HealthReport, error) {
+	rt, err := s.Runtime()
+	if err != nil {
+		return HealthReport{}, err
+	}
+
+	containers, err := rt.ListContainers(ctx, sessionFilter(s.id))
+	if err != nil {
+		return HealthReport{}, err
+	}
+	recorded, err := s.recordedContainers(ctx, rt, containers)
+	if err != nil {
+		return HealthReport{}, err
+	}
+	containers = append(containers, recorded...)
+
+	report := evaluateContainers(s.ServiceNames(), containers)
+
+	for _, c := range cs {
+		checker, ok := c.(ReadinessChecker)
+		if !ok {
+			continue
+		}
+		owned := ownedServices(c)
+		if !report.allHealthy(owned) {
+			// No point in checking readiness of components with missing or stopped containers.
+			continue
+		}
+
+		cctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+		err := checker.CheckReady(cctx, s)
+		cancel()
+		if err != nil {
+			report.markUnhealthy(owned, fmt.Errorf("not ready: %w", err))
+		}
+	}
+
+	return report, nil
+}
+
+// EnsureHealthy validates the session and applies the policy to unhealthy services.
+// It reports whether the session was repaired, in which case service addresses may have changed.
+func (s *Session) EnsureHealthy(ctx context.Context, policy HealthPolicy, cs ...ContextComponent) (bool, error) {
+	report, err := s.Validate(ctx, cs...)
+	if err != nil {
+		return false, err
+	}
+	if report.Healthy() {
+		return false, nil
+	}
+
+	switch policy {
+	case RestartUnhealthy:
+		return true, s.restartUnhealthy(ctx, report, cs)
+	case RecreateSession:
+		return true, s.recreate(ctx, cs)
+	case FailUnhealthy:
+		return false, &UnhealthySessionError{Report: report}
+	default:
+		return false, fmt.Errorf("unknown health policy %d", policy)
+	}
+}
+
+func (s *Session) restartUnhealthy(ctx context.Context, report HealthReport, cs []ContextComponent) error {
+	unhealthy := map[string]bool{}
+	for _, svc := range report.Unhealthy() {
+		unhealthy[svc.Service] = true
+	}
+
+	var restart []ContextComponent
+	for _, c := range cs {
+		for _, svc := range ownedServices(c) {
+			if unhealthy[svc] {
+				restart = append(restart, c)
+				delete(unhealthy, svc)
+				break
+			}
+		}
+	}
+	for _, c := range cs {
+		for _, svc := range ownedServices(c) {
+			delete(unhealthy, svc)
+		}
+	}
+	if len(unhealthy) > 0 {
+		names := make([]string, 0, len(unhealthy))
+		for svc := range unhealthy {
+			names = append(names, svc)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("no component provided for unhealthy services: %s", strings.Join(names, ", "))
+	}
+
+	for _, c := range restart {
+		fmt.Printf("Restarting component %q\n", componentName(c))
+		if err := c.Stop(ctx, s); err != nil {
+			return err
+		}
+		s.unregisterServices(ownedServices(c))
+	}
+
+	return s.StartComponentsContext(ctx, restart...)
+}
+
+func (s *Session) recreate(ctx context.Context, cs []ContextComponent) error {
+	fmt.Printf("Recreating session %q\n", s.id)
+
+	rt, err := s.Runtime()
+	if err != nil {
+		return err
+	}
+
+	containers, err := rt.ListContainers(ctx, sessionFilter(s.id))
+	if err != nil {
+		return err
+	}
+	recorded, err := s.recordedContainers(ctx, rt, containers)
+	if err != nil {
+		return err
+	}
+	for _, c := range append(containers, recorded...) {
+		if err := rt.RemoveContainer(ctx, c.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := rt.InspectNetwork(ctx, s.networkID); err != nil {
+		var noSuchNetwork *docker.NoSuchNetwork
+		if !errors.As(err, &noSuchNetwork) {
+			return err
+		}
+		networkID, err := createNetwork(ctx, rt, s.id)
+		if err != nil {
+			return err
+		}
+		s.networkID = networkID
+		s.ownsNetwork = true
+	}
+	if err := s.forgetMissingNetworks(ctx, rt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.serviceAddresses = map[string]string{}
+	s.hostMappedServiceAddresses = map[string]string{}
+	s.aliasServiceAddresses = map[string]string{}
+	s.endpoints = nil
+	s.services = nil
+	s.resources = nil
+	s.mu.Unlock()
+
+	return s.StartComponentsContext(ctx, cs...)
+}
+
+func (s *Session) unregisterServices(serviceNames []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, svc := range serviceNames {
+		delete(s.serviceAddresses, svc)
+		delete(s.hostMappedServiceAddresses, svc)
+		delete(s.aliasServiceAddresses, svc)
+		delete(s.endpoints, svc)
+		delete(s.services, svc)
+	}
+}
+
+// evaluateContainers matches registered services to the session containers through the service label.
+func evaluateContainers(serviceNames []string, containers []docker.APIContainers) HealthReport {
+	byService := map[string]docker.APIContainers{}
+	for _, c := range containers {
+		for _, svc := range serviceLabelNames(c) {
+			byService[svc] = c
+		}
+	}
+
+	sort.Strings(serviceNames)
+	report := HealthReport{Services: make([]ServiceHealth, 0, len(serviceNames))}
+	for _, svc := range serviceNames {
+		h := ServiceHealth{Service: svc, State: "missing"}
+		c, ok := byService[svc]
+		switch {
+		case !ok:
+			h.Err = errors.New("container not found")
+		case c.State != "running":
+			h.State = c.State
+			h.Err = fmt.Errorf("container is %s: %s", c.State, c.Status)
+		default:
+			h.State = c.State
+		}
+		if ok {
+			h.Component = c.Labels[LabelComponent]
+			h.Container = containerName(c)
+		}
+		report.Services = append(report.Services, h)
+	}
+	return report
+}
+
+func (r HealthReport) allHealthy(serviceNames []string) bool {
+	for _, svc := range serviceNames {
+		for _, h := range r.Services {
+			if h.Service == svc && h.Err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r HealthReport) markUnhealthy(serviceNames []string, err error) {
+	for _, svc := range serviceNames {
+		for i := range r.Services {
+			if r.Services[i].Service == svc {
+				r.Services[i].Err = err
+			}
+		}
+	}
+}
+
+func ownedServices(c ContextComponent) []string {
+	if dc, ok := c.(DependentComponent); ok {
+		return dc.ServiceNames()
+	}
+	return nil
+}
+
+func componentName(c ContextComponent) string {
+	if dc, ok := c.(DependentComponent); ok {
+		return dc.ComponentName()
+	}
+	return fmt.Sprintf("%T", c)
+}
@@ -0,0 +1,197 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateContainers(t *testing.T) {
+	containers := []docker.APIContainers{
+		{
+			Names:  []string{"/000-kafka"},
+			State:  "running",
+			Labels: map[string]string{LabelService: "kafka", LabelComponent: "kafka"},
+		},
+		{
+			Names:  []string{"/000-zookeeper"},
+			State:  "exited",
+			Status: "Exited (1) 2 minutes ago",
+			Labels: map[string]string{LabelService: "zookeeper", LabelComponent: "kafka"},
+		},
+	}
+
+	report := evaluateContainers([]string{"zookeeper", "redis", "kafka"}, containers)
+
+	require.Len(t, report.Services, 3)
+	assert.Equal(t, ServiceHealth{Service: "kafka", Component: "kafka", Container: "000-kafka", State: "running"},
+		report.Services[0])
+
+	assert.Equal(t, "redis", report.Services[1].Service)
+	assert.Equal(t, "missing", report.Services[1].State)
+	require.EqualError(t, report.Services[1].Err, "container not found")
+
+	assert.Equal(t, "000-zookeeper", report.Services[2].Container)
+	require.EqualError(t, report.Services[2].Err, "container is exited: Exited (1) 2 minutes ago")
+
+	assert.False(t, report.Healthy())
+	assert.Len(t, report.Unhealthy(), 2)
+}
+
+func TestUnhealthySessionError(t *testing.T) {
+	err := &UnhealthySessionError{Report: HealthReport{Services: []ServiceHealth{
+		{Service: "kafka", Container: "000-kafka", State: "running"},
+		{Service: "redis", State: "missing", Err: errors.New("container not found")},
+	}}}
+
+	assert.EqualError(t, err, `session has 1 unhealthy services: redis
+kafka (container "000-kafka", state running): healthy
+redis (container "", state missing): container not found
+`)
+}
+
+func TestHealthReportMarkUnhealthy(t *testing.T) {
+	report := HealthReport{Services: []ServiceHealth{{Service: "kafka"}, {Service: "zookeeper"}, {Service: "redis"}}}
+	require.True(t, report.allHealthy([]string{"kafka", "zookeeper"}))
+
+	report.markUnhealthy([]string{"kafka", "zookeeper"}, errors.New("not ready"))
+	assert.False(t, report.allHealthy([]string{"kafka"}))
+	assert.True(t, report.allHealthy([]string{"redis"}))
+}
+
+func TestRestartUnhealthyWithoutComponent(t *testing.T) {
+	report := HealthReport{Services: []ServiceHealth{{Service: "redis", Err: errors.New("container not found")}}}
+	_, cs := newFakeComponents(fakeComponent{name: "kafka", services: []string{"kafka"}})
+
+	err := newTestSession().restartUnhealthy(context.Background(), report, cs)
+	require.EqualError(t, err, "no component provided for unhealthy services: redis")
+}
+
+func TestSimpleComponentCheckReady(t *testing.T) {
+	errNotReady := errors.New("not ready")
+	c := &SimpleComponent{
+		Name: "redis",
+		Containers: []SimpleContainerConfig{{
+			Name:             "redis",
+			ReadyContextFunc: func(context.Context, *Session) error { return errNotReady },
+		}},
+	}
+
+	err := c.CheckReady(context.Background(), newTestSession())
+	require.ErrorIs(t, err, errNotReady)
+}
+
+func TestEnsureHealthyRestartsExitedContainer(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	c := newFakeRedis(nil)
+	require.NoError(t, sess.StartComponentsContext(context.Background(), c))
+
+	report, err := sess.Validate(context.Background(), c)
+	require.NoError(t, err)
+	assert.True(t, report.Healthy())
+
+	require.NoError(t, rt.ExitContainer("000-redis", 137))
+	report, err = sess.Validate(context.Background(), c)
+	require.NoError(t, err)
+	require.Len(t, report.Unhealthy(), 1)
+	assert.Equal(t, "exited", report.Unhealthy()[0].State)
+
+	repaired, err := sess.EnsureHealthy(context.Background(), RestartUnhealthy, c)
+	require.NoError(t, err)
+	assert.True(t, repaired)
+	container, err := rt.InspectContainer(context.Background(), "000-redis")
+	require.NoError(t, err)
+	assert.True(t, container.State.Running)
+}
+
+func TestLoadHealthySession(t *testing.T) {
+	tests := map[string]struct {
+		policy        HealthPolicy
+		err           string
+		mongoReplaced bool
+	}{
+		"fail":     {policy: FailUnhealthy, err: "session has 1 unhealthy services: redis"},
+		"restart":  {policy: RestartUnhealthy},
+		"recreate": {policy: RecreateSession, mongoReplaced: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			rt := NewFakeRuntime()
+			sess := newFakeSession(t, rt)
+			cs := []ContextComponent{
+				&SimpleComponent{Name: "redis", Containers: []SimpleContainerConfig{{
+					Name: "redis", Repository: "redis", Tag: "7-alpine", ServicePorts: map[string]string{"redis": "6379"},
+				}}},
+				&SimpleComponent{Name: "mongo", Containers: []SimpleContainerConfig{{
+					Name: "mongo", Repository: "mongo", Tag: "7", ServicePorts: map[string]string{"mongo": "27017"},
+				}}},
+			}
+			require.NoError(t, sess.StartComponentsContext(ctx, cs...))
+			fpath := filepath.Join(t.TempDir(), DefaultSessionFile)
+			require.NoError(t, sess.PersistToFile(fpath))
+			mongo, err := rt.InspectContainer(ctx, "000-mongo")
+			require.NoError(t, err)
+
+			require.NoError(t, rt.ExitContainer("000-redis", 137))
+
+			loaded, err := LoadHealthySession(ctx, fpath, tt.policy, cs, WithRuntime(rt))
+			if tt.err != "" {
+				var unhealthy *UnhealthySessionError
+				require.ErrorAs(t, err, &unhealthy)
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			report, err := loaded.Validate(ctx, cs...)
+			require.NoError(t, err)
+			assert.True(t, report.Healthy())
+			current, err := rt.InspectContainer(ctx, "000-mongo")
+			require.NoError(t, err)
+			assert.Equal(t, tt.mongoReplaced, current.ID != mongo.ID)
+		})
+	}
+}
+
+func TestValidateRecordedContainer(t *testing.T) {
+	ctx := context.Background()
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	c := &SimpleComponent{Name: "redis", Containers: []SimpleContainerConfig{{
+		Name: "redis", Repository: "redis", Tag: "7-alpine", ServicePorts: map[string]string{"redis": "6379"},
+	}}}
+	require.NoError(t, sess.StartComponentsContext(ctx, c))
+	info, ok := sess.ServiceInfo("redis")
+	require.True(t, ok)
+
+	// The container lost its labels, it is found by the recorded ID.
+	rt.mu.Lock()
+	rt.containers[info.ContainerID].container.Config.Labels = nil
+	rt.mu.Unlock()
+
+	report, err := sess.Validate(ctx, c)
+	require.NoError(t, err)
+	assert.Equal(t, []ServiceHealth{{Service: "redis", Component: "redis", Container: "000-redis", State: "running"}},
+		report.Services)
+
+	require.NoError(t, rt.ExitContainer(info.ContainerID, 1))
+	report, err = sess.Validate(ctx, c)
+	require.NoError(t, err)
+	require.Len(t, report.Unhealthy(), 1)
+	assert.Equal(t, "exited", report.Unhealthy()[0].State)
+
+	require.NoError(t, sess.recreate(ctx, []ContextComponent{c}))
+	_, err = rt.InspectContainer(ctx, info.ContainerID)
+	var noSuchContainer *docker.NoSuchContainer
+	require.ErrorAs(t, err, &noSuchContainer)
+	report, err = sess.Validate(ctx, c)
+	require.NoError(t, err)
+	assert.True(t, report.Healthy())
+}
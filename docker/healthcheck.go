@@ -0,0 +1,94 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// HealthCheck defines the Docker healthcheck of a container. Zero values inherit the image's settings.
+type HealthCheck struct {
+	// Test is the check to run, e.g. {"CMD-SHELL", "curl -f http://localhost/health"}.
+	// An empty test reuses the image's own HEALTHCHECK.
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	// Retries is the number of consecutive failures after which the container is unhealthy.
+	Retries int
+}
+
+// WithHealthCheck waits for the container to be healthy according to the healthcheck before it is ready.
+func WithHealthCheck(hc HealthCheck) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.HealthCheck = &hc
+	}
+}
+
+// WithImageHealthCheck waits for the container to be healthy according to the image's HEALTHCHECK before it is ready.
+func WithImageHealthCheck() SimpleContainerOptionFunc {
+	return WithHealthCheck(HealthCheck{})
+}
+
+// healthConfig converts a healthcheck to the Docker container config, nil inherits the image's healthcheck.
+func healthConfig(hc *HealthCheck) *docker.HealthConfig {
+	if hc == nil {
+		return nil
+	}
+	if len(hc.Test) == 0 && hc.Interval == 0 && hc.Timeout == 0 && hc.StartPeriod == 0 && hc.Retries == 0 {
+		return nil
+	}
+	return &docker.HealthConfig{
+		Test:        hc.Test,
+		Interval:    hc.Interval,
+		Timeout:     hc.Timeout,
+		StartPeriod: hc.StartPeriod,
+		Retries:     hc.Retries,
+	}
+}
+
+// waitHealthy polls the health status of a container until it is healthy.
+// An unhealthy or stopped container, or one without a healthcheck, is a permanent error.
+func waitHealthy(ctx context.Context, rt Runtime, containerName string) error {
+	return RetryContext(ctx, func() error {
+		c, err := rt.InspectContainer(ctx, containerName)
+		if err != nil {
+			return err
+		}
+		return containerHealth(c)
+	})
+}
+
+// containerHealth returns nil if the container is healthy, or an error describing its health.
+func containerHealth(c *docker.Container) error {
+	name := strings.TrimPrefix(c.Name, "/")
+	if !c.State.Running {
+		return backoff.Permanent(fmt.Errorf("container %s is %s", name, c.State.Status))
+	}
+
+	switch c.State.Health.Status {
+	case "healthy":
+		return nil
+	case "starting":
+		return fmt.Errorf("container %s is starting", name)
+	case "unhealthy":
+		return backoff.Permanent(fmt.Errorf("container %s is unhealthy: %s", name, lastHealthOutput(c.State.Health)))
+	case "":
+		return backoff.Permanent(errors.New("container " + name + " has no healthcheck"))
+	default:
+		return fmt.Errorf("container %s health is %s", name, c.State.Health.Status)
+	}
+}
+
+func lastHealthOutput(h docker.Health) string {
+	if len(h.Log) == 0 {
+		return "no healthcheck output"
+	}
+	last := h.Log[len(h.Log)-1]
+	return fmt.Sprintf("exit code %d: %s", last.ExitCode, strings.TrimSpace(last.Output))
+}
@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthConfig(t *testing.T) {
+	assert.Nil(t, healthConfig(nil))
+	assert.Nil(t, healthConfig(&HealthCheck{}))
+
+	hc := healthConfig(&HealthCheck{Test: []string{"CMD", "true"}, Interval: time.Second, Retries: 3})
+	assert.Equal(t, &docker.HealthConfig{Test: []string{"CMD", "true"}, Interval: time.Second, Retries: 3}, hc)
+
+	// Overriding the interval keeps the image's test.
+	hc = healthConfig(&HealthCheck{Interval: time.Second})
+	assert.Equal(t, &docker.HealthConfig{Interval: time.Second}, hc)
+}
+
+func TestContainerHealth(t *testing.T) {
+	container := func(running bool, status string, log ...docker.HealthCheck) *docker.Container {
+		return &docker.Container{
+			Name: "/000-redis",
+			State: docker.State{
+				Running: running,
+				Status:  "exited",
+				Health:  docker.Health{Status: status, Log: log},
+			},
+		}
+	}
+
+	assert.NoError(t, containerHealth(container(true, "healthy")))
+	assert.EqualError(t, containerHealth(container(true, "starting")), "container 000-redis is starting")
+	assert.EqualError(t, containerHealth(container(true, "unhealthy", docker.HealthCheck{ExitCode: 1, Output: "down\n"})),
+		"container 000-redis is unhealthy: exit code 1: down")
+	assert.EqualError(t, containerHealth(container(true, "")), "container 000-redis has no healthcheck")
+	assert.EqualError(t, containerHealth(container(false, "starting")), "container 000-redis is exited")
+}
@@ -0,0 +1,126 @@
+package docker
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// defaultHostAddress is the host published ports are reached at when the daemon runs on the local machine.
+const defaultHostAddress = "localhost"
+
+var (
+	// containerEnvFiles mark a process running inside a container, of Docker and Podman respectively.
+	containerEnvFiles = []string{"/.dockerenv", "/run/.containerenv"}
+	// defaultDockerSocket is the socket of a rootful Docker daemon, used unless DOCKER_HOST is set.
+	defaultDockerSocket = "/var/run/docker.sock"
+	// podmanSocket is the Docker compatible socket of a rootful Podman service.
+	podmanSocket = "/run/podman/podman.sock"
+)
+
+// InDocker indicates whether the current process is running inside a Docker or Podman container.
+func InDocker() bool {
+	for _, f := range containerEnvFiles {
+		if _, err := os.Stat(f); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WithHostAddress sets the host published service ports are reached at from outside the containers, e.g. when the
+// ports of a remote daemon are forwarded. It can also be set with the BAKE_HOST_ADDRESS env var.
+func WithHostAddress(host string) SessionOptionFunc {
+	return func(s *Session) {
+		s.hostAddress = host
+	}
+}
+
+// HostAddress returns the host published service ports are reached at from outside the containers. Unless set with
+// WithHostAddress or BAKE_HOST_ADDRESS, it is the host of a TCP daemon endpoint, e.g. from DOCKER_HOST, and
+// localhost for local daemons, including rootless Docker and Podman.
+func (s *Session) HostAddress() string {
+	if s.hostAddress != "" {
+		return s.hostAddress
+	}
+	if host := os.Getenv("BAKE_HOST_ADDRESS"); host != "" {
+		return host
+	}
+
+	s.clientMu.Lock()
+	endpoint := s.dockerEndpoint
+	if s.client != nil {
+		endpoint = s.client.Endpoint()
+	}
+	s.clientMu.Unlock()
+	if endpoint == "" {
+		endpoint = os.Getenv("DOCKER_HOST")
+	}
+	return daemonHost(endpoint)
+}
+
+// daemonHost returns the host of a daemon endpoint, localhost for local sockets.
+func daemonHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return defaultHostAddress
+	}
+	switch u.Scheme {
+	case "tcp", "http", "https":
+		host := u.Hostname()
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			return defaultHostAddress
+		}
+		return host
+	default:
+		return defaultHostAddress
+	}
+}
+
+// newDockerClient creates a client of the daemon at the endpoint. Without an endpoint the one of DOCKER_HOST is used,
+// falling back to the local socket of Docker, rootless Docker or Podman, whichever exists.
+func newDockerClient(endpoint string) (*docker.Client, error) {
+	if endpoint == "" {
+		endpoint = localDaemonEndpoint()
+	}
+	if strings.HasPrefix(endpoint, "ssh://") || endpoint == "" && strings.HasPrefix(os.Getenv("DOCKER_HOST"), "ssh://") {
+		return nil, errors.New("ssh daemon endpoints are not supported, forward the daemon socket with " +
+			"ssh -L and use a unix:// or tcp:// endpoint instead")
+	}
+	pool, err := dockertest.NewPool(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return pool.Client, nil
+}
+
+// localDaemonEndpoint finds the socket of a local daemon when neither DOCKER_HOST nor the default Docker socket
+// exist, or returns an empty endpoint to leave the choice to dockertest.
+func localDaemonEndpoint() string {
+	if os.Getenv("DOCKER_HOST") != "" || socketExists(defaultDockerSocket) {
+		return ""
+	}
+
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "docker.sock"), filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, podmanSocket)
+	for _, socket := range candidates {
+		if socketExists(socket) {
+			return "unix://" + socket
+		}
+	}
+	return ""
+}
+
+func socketExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&fs.ModeSocket != 0
+}
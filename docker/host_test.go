@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonHost(t *testing.T) {
+	tests := map[string]string{
+		"":                            "localhost",
+		"unix:///var/run/docker.sock": "localhost",
+		"npipe:////./pipe/docker":     "localhost",
+		"tcp://10.0.0.2:2376":         "10.0.0.2",
+		"tcp://0.0.0.0:2375":          "localhost",
+		"tcp://[::]:2375":             "localhost",
+		"tcp://[fd00::2]:2375":        "fd00::2",
+		"https://docker.example:2376": "docker.example",
+		"ssh://user@build-host":       "localhost",
+		"tcp://%zz":                   "localhost",
+	}
+	for endpoint, want := range tests {
+		assert.Equal(t, want, daemonHost(endpoint), endpoint)
+	}
+}
+
+func TestSessionHostAddress(t *testing.T) {
+	t.Setenv("BAKE_HOST_ADDRESS", "")
+	t.Setenv("DOCKER_HOST", "")
+
+	s := &Session{}
+	assert.Equal(t, "localhost", s.HostAddress())
+
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.2:2376")
+	assert.Equal(t, "10.0.0.2", s.HostAddress())
+
+	s = &Session{dockerEndpoint: "tcp://build-host:2376"}
+	assert.Equal(t, "build-host", s.HostAddress())
+
+	t.Setenv("BAKE_HOST_ADDRESS", "127.0.0.1")
+	assert.Equal(t, "127.0.0.1", s.HostAddress())
+
+	s = &Session{}
+	WithHostAddress("10.0.0.3")(s)
+	assert.Equal(t, "10.0.0.3", s.HostAddress())
+}
+
+func TestNewDockerClientSSH(t *testing.T) {
+	_, err := newDockerClient("ssh://user@build-host")
+	require.ErrorContains(t, err, "ssh daemon endpoints are not supported")
+
+	t.Setenv("DOCKER_HOST", "ssh://user@build-host")
+	_, err = newDockerClient("")
+	require.ErrorContains(t, err, "ssh daemon endpoints are not supported")
+}
+
+func TestInDocker(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, ".containerenv")
+	orig := containerEnvFiles
+	t.Cleanup(func() { containerEnvFiles = orig })
+	containerEnvFiles = []string{filepath.Join(dir, ".dockerenv"), marker}
+
+	assert.False(t, InDocker())
+	require.NoError(t, os.WriteFile(marker, nil, 0o600))
+	assert.True(t, InDocker())
+}
+
+func TestLocalDaemonEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	origDocker, origPodman := defaultDockerSocket, podmanSocket
+	t.Cleanup(func() { defaultDockerSocket, podmanSocket = origDocker, origPodman })
+	defaultDockerSocket = filepath.Join(dir, "docker.sock")
+	podmanSocket = filepath.Join(dir, "podman.sock")
+
+	runtimeDir := filepath.Join(dir, "run")
+	require.NoError(t, os.MkdirAll(filepath.Join(runtimeDir, "podman"), 0o750))
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv("DOCKER_HOST", "")
+
+	listen := func(t *testing.T, path string) {
+		l, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = l.Close() })
+	}
+
+	assert.Empty(t, localDaemonEndpoint())
+
+	rootlessPodman := filepath.Join(runtimeDir, "podman", "podman.sock")
+	listen(t, rootlessPodman)
+	assert.Equal(t, "unix://"+rootlessPodman, localDaemonEndpoint())
+
+	rootlessDocker := filepath.Join(runtimeDir, "docker.sock")
+	listen(t, rootlessDocker)
+	assert.Equal(t, "unix://"+rootlessDocker, localDaemonEndpoint())
+
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.2:2376")
+	assert.Empty(t, localDaemonEndpoint())
+
+	t.Setenv("DOCKER_HOST", "")
+	listen(t, defaultDockerSocket)
+	assert.Empty(t, localDaemonEndpoint())
+}
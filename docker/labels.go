@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+const (
+	// LabelSession is the Docker label holding the ID of the session which created a resource.
+	LabelSession = "com.beatlabs.bake.session"
+	// LabelComponent is the Docker label holding the name of the component which created a resource.
+	LabelComponent = "com.beatlabs.bake.component"
+	// LabelService is the Docker label holding the comma separated service names a container provides.
+	LabelService = "com.beatlabs.bake.service"
+	// LabelCreated is the Docker label holding the RFC 3339 creation time of a resource.
+	LabelCreated = "com.beatlabs.bake.created"
+)
+
+// resourceLabels returns the labels every Docker resource created by bake carries.
+func resourceLabels(sessionID, component string, services ...string) map[string]string {
+	labels := map[string]string{
+		LabelSession: sessionID,
+		LabelCreated: time.Now().UTC().Format(time.RFC3339),
+	}
+	if component != "" {
+		labels[LabelComponent] = component
+	}
+	if len(services) > 0 {
+		services = append([]string{}, services...)
+		sort.Strings(services)
+		labels[LabelService] = strings.Join(services, ",")
+	}
+	return labels
+}
+
+// sessionFilter is a Docker API filter selecting the resources of a session.
+func sessionFilter(sessionID string) map[string][]string {
+	return map[string][]string{"label": {LabelSession + "=" + sessionID}}
+}
+
+// serviceLabelNames lists the services a container provides according to its service label.
+func serviceLabelNames(c docker.APIContainers) []string {
+	if c.Labels[LabelService] == "" {
+		return nil
+	}
+	return strings.Split(c.Labels[LabelService], ",")
+}
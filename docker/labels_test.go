@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceLabels(t *testing.T) {
+	labels := resourceLabels("000", "kafka", "zookeeper", "kafka")
+
+	assert.Equal(t, "000", labels[LabelSession])
+	assert.Equal(t, "kafka", labels[LabelComponent])
+	assert.Equal(t, "kafka,zookeeper", labels[LabelService])
+	_, err := time.Parse(time.RFC3339, labels[LabelCreated])
+	require.NoError(t, err)
+}
+
+func TestResourceLabelsSessionOnly(t *testing.T) {
+	labels := resourceLabels("000", "")
+
+	assert.Len(t, labels, 2)
+	assert.Equal(t, map[string][]string{"label": {"com.beatlabs.bake.session=000"}}, sessionFilter("000"))
+}
+
+func TestCleanupReportString(t *testing.T) {
+	report := CleanupReport{
+		Containers: []string{"000-redis", "000-mongo"},
+		Networks:   []string{"abc"},
+	}
+
+	assert.Equal(t, "Removed 2 containers: 000-redis, 000-mongo\n"+
+		"Removed 0 images\n"+
+		"Removed 0 volumes\n"+
+		"Removed 1 networks: abc\n", report.String())
+}
+
+func TestCleanupSessionResources(t *testing.T) {
+	ctx := context.Background()
+	rt := NewFakeRuntime()
+	redis := func() *SimpleComponent {
+		return &SimpleComponent{Name: "redis", Containers: []SimpleContainerConfig{{
+			Name:         "redis",
+			Repository:   "redis",
+			Tag:          "7-alpine",
+			ServicePorts: map[string]string{"redis": "6379"},
+			Mounts:       []Mount{{Type: VolumeMount, Source: "data", Target: "/data"}},
+		}}}
+	}
+
+	sess := newFakeSession(t, rt)
+	require.NoError(t, sess.StartComponentsContext(ctx, redis()))
+	rt.AddLabeledImage("service:0123456789abcdef", resourceLabels("000", "service"))
+
+	otherNetworkID, err := rt.CreateNetwork(ctx, "111", resourceLabels("111", ""))
+	require.NoError(t, err)
+	other, err := NewSession("111", otherNetworkID, WithRuntime(rt))
+	require.NoError(t, err)
+	require.NoError(t, other.StartComponentsContext(ctx, redis()))
+
+	report, err := CleanupSessionResourcesContext(ctx, sess)
+	require.NoError(t, err)
+	assert.Equal(t, CleanupReport{
+		Containers: []string{"000-redis"},
+		Images:     []string{"service:0123456789abcdef"},
+		Volumes:    []string{"000-data"},
+		Networks:   []string{sess.NetworkID()},
+	}, report)
+
+	containers, err := rt.ListContainers(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, "111-redis", containerName(containers[0]))
+	volumes, err := rt.ListVolumes(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, volumes, 1)
+	assert.Equal(t, "111-data", volumes[0].Name)
+	networks, err := rt.ListNetworks(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+	assert.Equal(t, otherNetworkID, networks[0].ID)
+	_, err = rt.InspectImage(ctx, "redis:7-alpine")
+	require.NoError(t, err)
+}
@@ -0,0 +1,202 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ory/dockertest/v3/docker"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultImageLockFile is the file name used for storing image locks.
+const DefaultImageLockFile = "bake-images.lock"
+
+// ImageLock pins the images of components to their digests, for reproducible sessions.
+type ImageLock struct {
+	// Images maps image references, e.g. "wurstmeister/kafka:latest", to digest references,
+	// e.g. "wurstmeister/kafka@sha256:...".
+	Images map[string]string `json:"images"`
+}
+
+// WithImageLock runs the images of the session by the digests of the lock.
+// It can also be set with the BAKE_IMAGE_LOCK env var, holding the path of the lock file.
+func WithImageLock(lock *ImageLock) SessionOptionFunc {
+	return func(s *Session) {
+		s.imageLock = lock
+	}
+}
+
+// imageLockFromEnv loads the lock file the BAKE_IMAGE_LOCK env var points to, if any.
+func imageLockFromEnv() (*ImageLock, error) {
+	fpath := os.Getenv("BAKE_IMAGE_LOCK")
+	if fpath == "" {
+		return nil, nil
+	}
+	return LoadImageLock(fpath)
+}
+
+// LoadImageLock loads an image lock from a file.
+func LoadImageLock(fpath string) (*ImageLock, error) {
+	data, err := os.ReadFile(filepath.Clean(fpath))
+	if err != nil {
+		return nil, err
+	}
+
+	var l ImageLock
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("parse image lock %s: %w", fpath, err)
+	}
+	if l.Images == nil {
+		l.Images = map[string]string{}
+	}
+	return &l, nil
+}
+
+// PersistToFile stores the image lock in a file.
+func (l *ImageLock) PersistToFile(fpath string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fpath, append(data, '\n'), 0o600)
+}
+
+// ResolveImageLock pulls the images of the components and locks them to their current digests.
+// Images are pulled according to the rewrite rules of the BAKE_IMAGE_REWRITE env var, the lock references the
+// original repositories.
+func ResolveImageLock(ctx context.Context, cs ...ImageLister) (*ImageLock, error) {
+	rules, err := imageRewritesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDockerClient("")
+	if err != nil {
+		return nil, err
+	}
+
+	l := &ImageLock{Images: map[string]string{}}
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	for _, img := range listImages(cs) {
+		g.Go(func() error {
+			pulled := img
+			pulled.Repository = rewriteRepository(img.Repository, rules)
+			fmt.Printf("Resolving image %s\n", pulled)
+			err := client.PullImage(docker.PullImageOptions{
+				Repository: pulled.Repository,
+				Tag:        pulled.tag(),
+				Platform:   pulled.Platform,
+				Context:    ctx,
+			}, pullAuth(pulled))
+			if err != nil {
+				return fmt.Errorf("pull image %s: %w", pulled, err)
+			}
+
+			inspected, err := client.InspectImage(pulled.String())
+			if err != nil {
+				return fmt.Errorf("inspect image %s: %w", pulled, err)
+			}
+			digest, err := repoDigest(pulled.Repository, inspected.RepoDigests)
+			if err != nil {
+				return fmt.Errorf("image %s: %w", pulled, err)
+			}
+			_, digest, _ = strings.Cut(digest, "@")
+
+			mu.Lock()
+			defer mu.Unlock()
+			l.Images[img.String()] = img.Repository + "@" + digest
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Verify checks that the lock pins exactly the images of the components.
+func (l *ImageLock) Verify(cs ...ImageLister) error {
+	images := listImages(cs)
+	wanted := make(map[string]bool, len(images))
+	var missing, stale []string
+	for _, img := range images {
+		wanted[img.String()] = true
+		if _, ok := l.Images[img.String()]; !ok {
+			missing = append(missing, img.String())
+		}
+	}
+	for ref := range l.Images {
+		if !wanted[ref] {
+			stale = append(stale, ref)
+		}
+	}
+	if len(missing) == 0 && len(stale) == 0 {
+		return nil
+	}
+
+	sort.Strings(stale)
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("images not locked: %s", strings.Join(missing, ", ")))
+	}
+	if len(stale) > 0 {
+		errs = append(errs, fmt.Errorf("images locked but not used: %s", strings.Join(stale, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// lockedImage returns the image pinned to its digest by the session lock, if any.
+func (s *Session) lockedImage(img ImageRef) (ImageRef, error) {
+	if s.imageLock == nil {
+		return img, nil
+	}
+	digestRef, ok := s.imageLock.Images[img.String()]
+	if !ok {
+		return img, fmt.Errorf("image %s is not in the image lock", img)
+	}
+	_, digest, ok := strings.Cut(digestRef, "@")
+	if !ok {
+		return img, fmt.Errorf("image lock of %s is not a digest reference: %s", img, digestRef)
+	}
+	img.Digest = digest
+	return img, nil
+}
+
+// listImages lists the distinct images of the components.
+func listImages(cs []ImageLister) []ImageRef {
+	var images []ImageRef
+	seen := map[ImageRef]bool{}
+	for _, c := range cs {
+		for _, img := range c.Images() {
+			if !seen[img] {
+				seen[img] = true
+				images = append(images, img)
+			}
+		}
+	}
+	return images
+}
+
+// repoDigest picks the digest reference of the repository among the digests of an image.
+func repoDigest(repository string, digests []string) (string, error) {
+	for _, d := range digests {
+		if strings.HasPrefix(d, repository+"@") {
+			return d, nil
+		}
+	}
+	// Docker Hub images may be listed under their fully qualified name.
+	for _, d := range digests {
+		repo, digest, _ := strings.Cut(d, "@")
+		if strings.HasSuffix(repo, "/"+repository) {
+			return repository + "@" + digest, nil
+		}
+	}
+	return "", errors.New("no repository digest found, was the image pulled from a registry?")
+}
@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageLockPersistence(t *testing.T) {
+	fpath := filepath.Join(t.TempDir(), DefaultImageLockFile)
+	lock := &ImageLock{Images: map[string]string{"redis:7-alpine": "redis@sha256:abc"}}
+	require.NoError(t, lock.PersistToFile(fpath))
+
+	loaded, err := LoadImageLock(fpath)
+	require.NoError(t, err)
+	assert.Equal(t, lock, loaded)
+}
+
+func TestImageLockVerify(t *testing.T) {
+	c := &SimpleComponent{
+		Name: "kafka",
+		Containers: []SimpleContainerConfig{
+			{Name: "zookeeper", Repository: "wurstmeister/zookeeper"},
+			{Name: "kafka", Repository: "wurstmeister/kafka"},
+		},
+	}
+
+	lock := &ImageLock{Images: map[string]string{
+		"wurstmeister/zookeeper:latest": "wurstmeister/zookeeper@sha256:abc",
+		"wurstmeister/kafka:latest":     "wurstmeister/kafka@sha256:def",
+	}}
+	require.NoError(t, lock.Verify(c))
+
+	lock.Images["redis:7-alpine"] = "redis@sha256:ghi"
+	delete(lock.Images, "wurstmeister/kafka:latest")
+	assert.EqualError(t, lock.Verify(c), "images not locked: wurstmeister/kafka:latest\n"+
+		"images locked but not used: redis:7-alpine")
+}
+
+func TestLockedImage(t *testing.T) {
+	img := ImageRef{Repository: "redis", Tag: "7-alpine"}
+
+	s := newTestSession()
+	unlocked, err := s.lockedImage(img)
+	require.NoError(t, err)
+	assert.Equal(t, "redis:7-alpine", unlocked.Reference())
+
+	s.imageLock = &ImageLock{Images: map[string]string{"redis:7-alpine": "redis@sha256:abc"}}
+	locked, err := s.lockedImage(img)
+	require.NoError(t, err)
+	assert.Equal(t, "redis@sha256:abc", locked.Reference())
+	assert.Equal(t, "redis:7-alpine", locked.String())
+
+	_, err = s.lockedImage(ImageRef{Repository: "mongo"})
+	assert.EqualError(t, err, "image mongo:latest is not in the image lock")
+}
+
+func TestRepoDigest(t *testing.T) {
+	d, err := repoDigest("redis", []string{"redis@sha256:abc"})
+	require.NoError(t, err)
+	assert.Equal(t, "redis@sha256:abc", d)
+
+	d, err = repoDigest("redis", []string{"docker.io/library/redis@sha256:abc"})
+	require.NoError(t, err)
+	assert.Equal(t, "redis@sha256:abc", d)
+
+	_, err = repoDigest("testservice", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// FailureLogTail is the number of log lines per container CaptureLogsOnFailure writes to the test output.
+var FailureLogTail = 100
+
+// TestingT is the part of testing.TB used by CaptureLogsOnFailure, so that the package does not depend on testing.
+type TestingT interface {
+	Failed() bool
+	Cleanup(func())
+	Logf(format string, args ...any)
+}
+
+// LogOptions configures fetching container logs.
+type LogOptions struct {
+	// Follow keeps streaming logs until the context is done or the container stops.
+	Follow bool
+	// Tail limits the output to the last lines, zero means all lines.
+	Tail int
+	// Since limits the output to lines logged after the time.
+	Since time.Time
+	// Timestamps prefixes each line with its timestamp.
+	Timestamps bool
+}
+
+// ServiceLogs writes the stdout and stderr logs of the container providing the service to w.
+func (s *Session) ServiceLogs(ctx context.Context, serviceName string, w io.Writer, opts LogOptions) error {
+	rt, err := s.Runtime()
+	if err != nil {
+		return err
+	}
+
+	c, err := s.serviceContainer(ctx, rt, serviceName)
+	if err != nil {
+		return err
+	}
+
+	return rt.ContainerLogs(ctx, c.ID, w, opts)
+}
+
+// DumpServiceLogs writes the logs of the containers providing the services to <dir>/<container name>.log.
+// All session containers are dumped when no service names are given.
+func (s *Session) DumpServiceLogs(ctx context.Context, dir string, serviceNames ...string) error {
+	rt, err := s.Runtime()
+	if err != nil {
+		return err
+	}
+
+	containers, err := s.logContainers(ctx, rt, serviceNames)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		fname := filepath.Join(dir, containerName(c)+".log")
+		if err := dumpContainerLogs(ctx, rt, c.ID, fname); err != nil {
+			return fmt.Errorf("dump logs of %s: %w", containerName(c), err)
+		}
+	}
+	return nil
+}
+
+// CaptureLogsOnFailure registers a cleanup which, when the test has failed, writes the last FailureLogTail log lines
+// of the containers providing the services to the test output. All session containers are captured when no service
+// names are given. If BAKE_LOGS_DIR is set, the full logs are written to files in that directory instead.
+func CaptureLogsOnFailure(t TestingT, s *Session, serviceNames ...string) {
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+
+		ctx := context.Background()
+		if dir := os.Getenv("BAKE_LOGS_DIR"); dir != "" {
+			if err := s.DumpServiceLogs(ctx, dir, serviceNames...); err != nil {
+				t.Logf("failed to dump container logs: %v", err)
+				return
+			}
+			t.Logf("container logs dumped to %s", dir)
+			return
+		}
+
+		rt, err := s.Runtime()
+		if err != nil {
+			t.Logf("failed to capture container logs: %v", err)
+			return
+		}
+		containers, err := s.logContainers(ctx, rt, serviceNames)
+		if err != nil {
+			t.Logf("failed to capture container logs: %v", err)
+			return
+		}
+		for _, c := range containers {
+			var buf bytes.Buffer
+			if err := rt.ContainerLogs(ctx, c.ID, &buf, LogOptions{Tail: FailureLogTail}); err != nil {
+				t.Logf("failed to capture logs of %s: %v", containerName(c), err)
+				continue
+			}
+			t.Logf("last %d log lines of %s:\n%s", FailureLogTail, containerName(c), buf.String())
+		}
+	})
+}
+
+// serviceContainer finds the session container providing the service through the service label.
+func (s *Session) serviceContainer(ctx context.Context, rt Runtime, serviceName string) (docker.APIContainers, error) {
+	containers, err := s.logContainers(ctx, rt, []string{serviceName})
+	if err != nil {
+		return docker.APIContainers{}, err
+	}
+	return containers[0], nil
+}
+
+// logContainers lists the session containers providing the services, or all of them when no services are given.
+// Containers which lost their session labels are found by the container IDs recorded for their services.
+func (s *Session) logContainers(ctx context.Context, rt Runtime, serviceNames []string) ([]docker.APIContainers, error) {
+	containers, err := rt.ListContainers(ctx, sessionFilter(s.id))
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := s.recordedContainers(ctx, rt, containers)
+	if err != nil {
+		return nil, err
+	}
+	containers = append(containers, recorded...)
+	if len(serviceNames) == 0 {
+		return containers, nil
+	}
+
+	selected := make([]docker.APIContainers, 0, len(serviceNames))
+	for _, svc := range serviceNames {
+		found := false
+		for _, c := range containers {
+			if providesService(c, svc) {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no container found for service %q", svc)
+		}
+	}
+	return selected, nil
+}
+
+func providesService(c docker.APIContainers, serviceName string) bool {
+	for _, svc := range serviceLabelNames(c) {
+		if svc == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+func containerName(c docker.APIContainers) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID
+}
+
+func dumpContainerLogs(ctx context.Context, rt Runtime, id, fname string) error {
+	f, err := os.Create(filepath.Clean(fname))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			fmt.Printf("failed to close %s: %v\n", fname, err)
+		}
+	}()
+
+	return rt.ContainerLogs(ctx, id, f, LogOptions{})
+}
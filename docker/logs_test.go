@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingT struct {
+	failed   bool
+	cleanups []func()
+	logs     []string
+}
+
+func (t *recordingT) Failed() bool { return t.failed }
+
+func (t *recordingT) Cleanup(f func()) { t.cleanups = append(t.cleanups, f) }
+
+func (t *recordingT) Logf(format string, args ...any) {
+	t.logs = append(t.logs, fmt.Sprintf(format, args...))
+}
+
+func TestProvidesService(t *testing.T) {
+	c := docker.APIContainers{Labels: map[string]string{LabelService: "kafka,zookeeper"}}
+
+	assert.True(t, providesService(c, "kafka"))
+	assert.True(t, providesService(c, "zookeeper"))
+	assert.False(t, providesService(c, "redis"))
+	assert.False(t, providesService(docker.APIContainers{}, ""))
+}
+
+func TestContainerName(t *testing.T) {
+	assert.Equal(t, "000-redis", containerName(docker.APIContainers{ID: "abc", Names: []string{"/000-redis"}}))
+	assert.Equal(t, "abc", containerName(docker.APIContainers{ID: "abc"}))
+}
+
+func TestCaptureLogsOnFailure(t *testing.T) {
+	t.Setenv("BAKE_LOGS_DIR", "")
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	require.NoError(t, sess.StartComponentsContext(context.Background(), newFakeRedis(nil)))
+	info, ok := sess.ServiceInfo("redis")
+	require.True(t, ok)
+	require.NoError(t, rt.WriteLogs(info.ContainerID, "Ready to accept connections\n"))
+
+	// The container lost its labels, its logs are found by the recorded ID.
+	rt.mu.Lock()
+	rt.containers[info.ContainerID].container.Config.Labels = nil
+	rt.mu.Unlock()
+
+	passed := &recordingT{}
+	CaptureLogsOnFailure(passed, sess, "redis")
+	require.Len(t, passed.cleanups, 1)
+	passed.cleanups[0]()
+	assert.Empty(t, passed.logs)
+
+	failed := &recordingT{failed: true}
+	CaptureLogsOnFailure(failed, sess, "redis")
+	require.Len(t, failed.cleanups, 1)
+	failed.cleanups[0]()
+	assert.Equal(t, []string{"last 100 log lines of 000-redis:\nReady to accept connections\n"}, failed.logs)
+}
@@ -0,0 +1,181 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+const (
+	// sessionFileVersion is the version of the session file format. Files without a version are version 1, which
+	// only hold the session and network IDs and the service addresses.
+	sessionFileVersion = 2
+	// modulePath is the module path of bake, used to look up its version in the build info.
+	modulePath = "github.com/beatlabs/bake"
+)
+
+// ServiceInfo records where a service runs.
+type ServiceInfo struct {
+	// Component is the name of the component which started the service.
+	Component string
+	// Container is the name of the container of the service.
+	Container   string
+	ContainerID string
+	// Image is the reference the container was created from.
+	Image string
+	// Digest is the registry digest of the image, empty for images built by the session.
+	Digest string `json:",omitempty"`
+}
+
+// registerServiceInfo records where a service runs.
+func (s *Session) registerServiceInfo(serviceName string, info ServiceInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.services == nil {
+		s.services = map[string]ServiceInfo{}
+	}
+	s.services[serviceName] = info
+}
+
+// ServiceInfo returns where a service runs. Services of session files written by earlier bake versions, and services
+// registered by custom components, have none.
+func (s *Session) ServiceInfo(serviceName string) (ServiceInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.services[serviceName]
+	return info, ok
+}
+
+// recordedContainers finds the containers of services without a container labeled with the session, e.g. as the labels
+// were lost when the container was recreated by hand, by the container IDs recorded for them. Containers which no
+// longer exist are skipped.
+func (s *Session) recordedContainers(ctx context.Context, rt Runtime, labeled []docker.APIContainers,
+) ([]docker.APIContainers, error) {
+	labeledIDs := map[string]bool{}
+	labeledServices := map[string]bool{}
+	for _, c := range labeled {
+		labeledIDs[c.ID] = true
+		for _, svc := range serviceLabelNames(c) {
+			labeledServices[svc] = true
+		}
+	}
+
+	s.mu.Lock()
+	serviceNames := make([]string, 0, len(s.services))
+	services := make(map[string]ServiceInfo, len(s.services))
+	for svc, info := range s.services {
+		serviceNames = append(serviceNames, svc)
+		services[svc] = info
+	}
+	s.mu.Unlock()
+	sort.Strings(serviceNames)
+
+	var ids []string
+	recorded := map[string]docker.APIContainers{}
+	for _, svc := range serviceNames {
+		info := services[svc]
+		if info.ContainerID == "" || labeledServices[svc] || labeledIDs[info.ContainerID] {
+			continue
+		}
+
+		c, ok := recorded[info.ContainerID]
+		if !ok {
+			container, err := rt.InspectContainer(ctx, info.ContainerID)
+			if err != nil {
+				var noSuchContainer *docker.NoSuchContainer
+				if errors.As(err, &noSuchContainer) {
+					continue
+				}
+				return nil, fmt.Errorf("inspect container %s of service %s: %w", info.ContainerID, svc, err)
+			}
+			c = docker.APIContainers{
+				ID:     container.ID,
+				Names:  []string{container.Name},
+				State:  container.State.StateString(),
+				Status: container.State.String(),
+				Labels: map[string]string{LabelComponent: info.Component},
+			}
+			ids = append(ids, container.ID)
+		}
+		c.Labels[LabelService] = strings.Join(append(serviceLabelNames(c), svc), ",")
+		recorded[info.ContainerID] = c
+	}
+
+	containers := make([]docker.APIContainers, 0, len(ids))
+	for _, id := range ids {
+		containers = append(containers, recorded[id])
+	}
+	return containers, nil
+}
+
+// StartedAt returns the time the session was created, zero for session files written by earlier bake versions.
+func (s *Session) StartedAt() time.Time {
+	return s.startedAt
+}
+
+// OwnerPID returns the ID of the process which created the session, zero for session files written by earlier bake
+// versions.
+func (s *Session) OwnerPID() int {
+	return s.ownerPID
+}
+
+// BakeVersion returns the version of bake which created the session, empty when unknown.
+func (s *Session) BakeVersion() string {
+	return s.bakeVersion
+}
+
+// imageDigest returns the registry digest of an image, or an empty one if it has none, e.g. as it was built.
+func imageDigest(ctx context.Context, rt Runtime, image ImageRef) string {
+	if image.Digest != "" {
+		return image.Digest
+	}
+	inspected, err := rt.InspectImage(ctx, image.Reference())
+	if err != nil {
+		return ""
+	}
+	ref, err := repoDigest(image.Repository, inspected.RepoDigests)
+	if err != nil {
+		return ""
+	}
+	_, digest, _ := strings.Cut(ref, "@")
+	return digest
+}
+
+// bakeVersion returns the module version of bake the binary was built with.
+var bakeVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return ""
+})
+
+// checkSessionFileVersion rejects session files written by a newer bake, whose format is unknown.
+func checkSessionFileVersion(version int) error {
+	if version > sessionFileVersion {
+		return fmt.Errorf("session file version %d is not supported, the latest supported version is %d",
+			version, sessionFileVersion)
+	}
+	return nil
+}
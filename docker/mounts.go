@@ -0,0 +1,144 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// bakeSourceDir is where run-bake.sh mounts the working directory of the host, BAKE_HOST_PATH, inside the bake image.
+const bakeSourceDir = "/src"
+
+// MountType is the type of a container mount.
+type MountType string
+
+const (
+	// VolumeMount mounts a named volume, scoped to the session and removed along with it.
+	VolumeMount MountType = "volume"
+	// BindMount mounts a file or directory of the host.
+	BindMount MountType = "bind"
+	// TmpfsMount mounts an in-memory filesystem, e.g. for fast ephemeral databases.
+	TmpfsMount MountType = "tmpfs"
+)
+
+// Mount defines a volume, bind or tmpfs mount of a container.
+type Mount struct {
+	Type MountType
+	// Source is the volume name or the host path, relative paths are resolved against the working directory.
+	// It is not used by tmpfs mounts.
+	Source   string
+	Target   string
+	ReadOnly bool
+	// TmpfsSizeBytes limits the size of tmpfs mounts, zero means unlimited.
+	TmpfsSizeBytes int64
+}
+
+// WithVolume mounts a named volume of the session in a SimpleContainerConfig.
+func WithVolume(name, target string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.Mounts = append(c.Mounts, Mount{Type: VolumeMount, Source: name, Target: target})
+	}
+}
+
+// WithBindMount mounts a file or directory of the host read-only in a SimpleContainerConfig.
+func WithBindMount(source, target string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.Mounts = append(c.Mounts, Mount{Type: BindMount, Source: source, Target: target, ReadOnly: true})
+	}
+}
+
+// WithTmpfs mounts an in-memory filesystem in a SimpleContainerConfig.
+func WithTmpfs(target string, sizeBytes int64) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.Mounts = append(c.Mounts, Mount{Type: TmpfsMount, Target: target, TmpfsSizeBytes: sizeBytes})
+	}
+}
+
+// sessionVolumeName scopes a volume name to the session.
+func sessionVolumeName(sessionID, name string) string {
+	return sessionID + "-" + name
+}
+
+// hostMounts converts mounts to Docker mounts, creating the session volumes which do not exist yet.
+func hostMounts(ctx context.Context, session *Session, component string, mounts []Mount) ([]docker.HostMount, error) {
+	hms := make([]docker.HostMount, 0, len(mounts))
+	for _, m := range mounts {
+		if m.Target == "" {
+			return nil, fmt.Errorf("%s mount of %q has no target", m.Type, m.Source)
+		}
+
+		hm := docker.HostMount{Type: string(m.Type), Target: m.Target, ReadOnly: m.ReadOnly}
+		switch m.Type {
+		case VolumeMount:
+			hm.Source = sessionVolumeName(session.id, m.Source)
+			if err := ensureVolume(ctx, session, component, hm.Source); err != nil {
+				return nil, err
+			}
+		case BindMount:
+			source, err := bindMountSource(m.Source)
+			if err != nil {
+				return nil, err
+			}
+			hm.Source = source
+		case TmpfsMount:
+			if m.TmpfsSizeBytes > 0 {
+				hm.TempfsOptions = &docker.TempfsOptions{SizeBytes: m.TmpfsSizeBytes}
+			}
+		default:
+			return nil, fmt.Errorf("unknown mount type %q", m.Type)
+		}
+		hms = append(hms, hm)
+	}
+	return hms, nil
+}
+
+// ensureVolume creates a labeled session volume unless it exists already, e.g. shared with another container.
+func ensureVolume(ctx context.Context, session *Session, component, name string) error {
+	rt, err := session.Runtime()
+	if err != nil {
+		return err
+	}
+
+	_, err = rt.InspectVolume(ctx, name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, docker.ErrNoSuchVolume) {
+		return fmt.Errorf("inspect volume %s: %w", name, err)
+	}
+
+	if err := rt.CreateVolume(ctx, name, resourceLabels(session.id, component)); err != nil {
+		return fmt.Errorf("create volume %s: %w", name, err)
+	}
+	session.trackResource(volumeResource, name)
+	return nil
+}
+
+// bindMountSource resolves the host path of a bind mount. When running inside the bake image, the Docker daemon
+// sees the host paths, so paths under the mounted working directory are translated to BAKE_HOST_PATH.
+func bindMountSource(source string) (string, error) {
+	if source == "" {
+		return "", errors.New("bind mount has no source")
+	}
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return "", fmt.Errorf("resolve bind mount source %s: %w", source, err)
+	}
+	return translateHostPath(abs, os.Getenv("BAKE_HOST_PATH")), nil
+}
+
+func translateHostPath(path, hostPath string) string {
+	if hostPath == "" {
+		return path
+	}
+	rel, err := filepath.Rel(bakeSourceDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(hostPath, rel)
+}
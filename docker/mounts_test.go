@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateHostPath(t *testing.T) {
+	assert.Equal(t, "/home/me/repo/fixtures/init.js", translateHostPath("/src/fixtures/init.js", "/home/me/repo"))
+	assert.Equal(t, "/home/me/repo", translateHostPath("/src", "/home/me/repo"))
+	assert.Equal(t, "/tmp/init.js", translateHostPath("/tmp/init.js", "/home/me/repo"))
+	assert.Equal(t, "/srcfoo/init.js", translateHostPath("/srcfoo/init.js", "/home/me/repo"))
+	assert.Equal(t, "/src/init.js", translateHostPath("/src/init.js", ""))
+}
+
+func TestHostMounts(t *testing.T) {
+	t.Setenv("BAKE_HOST_PATH", "")
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	conf := SimpleContainerConfig{}
+	WithBindMount("testdata/init.js", "/docker-entrypoint-initdb.d/init.js")(&conf)
+	WithTmpfs("/data/db", 64<<20)(&conf)
+
+	hms, err := hostMounts(context.Background(), &Session{id: "000"}, "mongo", conf.Mounts)
+	require.NoError(t, err)
+	assert.Equal(t, []docker.HostMount{
+		{
+			Type:     "bind",
+			Source:   filepath.Join(wd, "testdata/init.js"),
+			Target:   "/docker-entrypoint-initdb.d/init.js",
+			ReadOnly: true,
+		},
+		{
+			Type:          "tmpfs",
+			Target:        "/data/db",
+			TempfsOptions: &docker.TempfsOptions{SizeBytes: 64 << 20},
+		},
+	}, hms)
+}
+
+func TestHostMountsInvalid(t *testing.T) {
+	_, err := hostMounts(context.Background(), &Session{id: "000"}, "mongo", []Mount{{Type: TmpfsMount}})
+	assert.EqualError(t, err, `tmpfs mount of "" has no target`)
+
+	_, err = hostMounts(context.Background(), &Session{id: "000"}, "mongo", []Mount{{Type: "nfs", Target: "/data"}})
+	assert.EqualError(t, err, `unknown mount type "nfs"`)
+}
@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// DefaultNetwork is the name of the session network, which containers join unless they list other networks.
+const DefaultNetwork = "default"
+
+// Networks returns the IDs of the session networks by name, the session network being DefaultNetwork.
+func (s *Session) Networks() map[string]string {
+	networks := s.namedNetworks()
+	networks[DefaultNetwork] = s.networkID
+	return networks
+}
+
+// containerNetworks returns the IDs of the named session networks, creating the missing ones.
+// No names means the session network.
+func (s *Session) containerNetworks(ctx context.Context, rt Runtime, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return []string{s.networkID}, nil
+	}
+
+	s.networksMu.Lock()
+	defer s.networksMu.Unlock()
+
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" {
+			return nil, errors.New("network name is required")
+		}
+		if name == DefaultNetwork {
+			ids = append(ids, s.networkID)
+			continue
+		}
+		if id, ok := s.networks[name]; ok {
+			ids = append(ids, id)
+			continue
+		}
+
+		fullName := s.id + "-" + name
+		fmt.Printf("Creating network %s\n", fullName)
+		id, err := rt.CreateNetwork(ctx, fullName, resourceLabels(s.id, ""))
+		if err != nil {
+			return nil, fmt.Errorf("create network %s: %w", fullName, err)
+		}
+		if s.networks == nil {
+			s.networks = map[string]string{}
+		}
+		s.networks[name] = id
+		s.trackResource(networkResource, id)
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// namedNetworks returns the IDs of the named session networks by name, excluding the session network.
+func (s *Session) namedNetworks() map[string]string {
+	s.networksMu.Lock()
+	defer s.networksMu.Unlock()
+
+	networks := make(map[string]string, len(s.networks))
+	for name, id := range s.networks {
+		networks[name] = id
+	}
+	return networks
+}
+
+// forgetNetwork drops a removed named network, it is created again when needed.
+func (s *Session) forgetNetwork(id string) {
+	s.networksMu.Lock()
+	defer s.networksMu.Unlock()
+
+	for name, networkID := range s.networks {
+		if networkID == id {
+			delete(s.networks, name)
+		}
+	}
+}
+
+// forgetMissingNetworks drops the named networks which no longer exist, they are created again when needed.
+func (s *Session) forgetMissingNetworks(ctx context.Context, rt Runtime) error {
+	s.networksMu.Lock()
+	defer s.networksMu.Unlock()
+
+	for name, id := range s.networks {
+		_, err := rt.InspectNetwork(ctx, id)
+		if err == nil {
+			continue
+		}
+		var noSuchNetwork *docker.NoSuchNetwork
+		if !errors.As(err, &noSuchNetwork) {
+			return err
+		}
+		delete(s.networks, name)
+	}
+	return nil
+}
+
+// endpointsConfig attaches a container to the networks under the aliases.
+func endpointsConfig(networkIDs, aliases []string) map[string]*docker.EndpointConfig {
+	endpoints := make(map[string]*docker.EndpointConfig, len(networkIDs))
+	for _, id := range networkIDs {
+		endpoints[id] = &docker.EndpointConfig{Aliases: aliases}
+	}
+	return endpoints
+}
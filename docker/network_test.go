@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeContainer(name string, networks ...string) *SimpleComponent {
+	return &SimpleComponent{
+		Name: name,
+		Containers: []SimpleContainerConfig{{
+			Name:         name,
+			Repository:   name,
+			Tag:          "latest",
+			ServicePorts: map[string]string{name: "8080"},
+			Networks:     networks,
+		}},
+	}
+}
+
+func containerNetworkNames(t *testing.T, rt *FakeRuntime, name string) []string {
+	t.Helper()
+
+	c, err := rt.InspectContainer(context.Background(), name)
+	require.NoError(t, err)
+	names := make([]string, 0, len(c.NetworkSettings.Networks))
+	for n := range c.NetworkSettings.Networks {
+		names = append(names, n)
+	}
+	return names
+}
+
+func TestSessionNetworks(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+
+	require.NoError(t, sess.StartComponentsContext(context.Background(),
+		newFakeContainer("kafka", "events"),
+		newFakeContainer("mongo"),
+		newFakeContainer("service", "events", DefaultNetwork),
+	))
+
+	assert.ElementsMatch(t, []string{"000-events"}, containerNetworkNames(t, rt, "000-kafka"))
+	assert.ElementsMatch(t, []string{"000"}, containerNetworkNames(t, rt, "000-mongo"))
+	assert.ElementsMatch(t, []string{"000", "000-events"}, containerNetworkNames(t, rt, "000-service"))
+
+	networks := sess.Networks()
+	assert.Len(t, networks, 2)
+	assert.Equal(t, sess.NetworkID(), networks[DefaultNetwork])
+	events, err := rt.InspectNetwork(context.Background(), networks["events"])
+	require.NoError(t, err)
+	assert.Equal(t, "000-events", events.Name)
+	assert.Equal(t, "000", events.Labels[LabelSession])
+
+	fpath := filepath.Join(t.TempDir(), DefaultSessionFile)
+	require.NoError(t, sess.PersistToFile(fpath))
+	loaded, err := LoadSessionFromFile(false, fpath, WithRuntime(rt))
+	require.NoError(t, err)
+	assert.Equal(t, networks, loaded.Networks())
+
+	// Both networks are labeled with the session, so cleanup and GC find them.
+	labeled, err := rt.ListNetworks(context.Background(), sessionFilter("000"))
+	require.NoError(t, err)
+	assert.Len(t, labeled, 2)
+}
+
+func TestSessionNetworksRollback(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+
+	require.NoError(t, sess.StartComponentsContext(context.Background(), newFakeContainer("mongo")))
+
+	failing := newFakeContainer("gateway", "edge", DefaultNetwork)
+	failing.Containers[0].ReadyContextFunc = func(context.Context, *Session) error {
+		return errors.New("not ready")
+	}
+	err := sess.StartComponentsContext(context.Background(), failing)
+	require.Error(t, err)
+
+	assert.NotContains(t, sess.Networks(), "edge")
+	_, err = rt.InspectNetwork(context.Background(), "000-edge")
+	require.Error(t, err)
+	_, err = rt.InspectNetwork(context.Background(), sess.NetworkID())
+	require.NoError(t, err)
+}
+
+func TestSessionNetworksEmptyName(t *testing.T) {
+	sess := newFakeSession(t, NewFakeRuntime())
+
+	err := sess.StartComponentsContext(context.Background(), newFakeContainer("mongo", ""))
+	assert.ErrorContains(t, err, "network name is required")
+}
@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// maxPortConflictAttempts bounds starting a container with preassigned host ports taken by someone else meanwhile.
+const maxPortConflictAttempts = 3
+
+// containerRunOptions returns the run options of a container along with the host ports known before it starts.
+// The remaining service ports are published on host ports assigned by Docker.
+func containerRunOptions(session *Session, component string, conf SimpleContainerConfig, env []string,
+) (*dockertest.RunOptions, map[string]string, error) {
+	runOpts := &dockertest.RunOptions{
+		Name:         session.id + "-" + conf.Name,
+		Tag:          conf.Tag,
+		Repository:   conf.Repository,
+		Env:          append([]string{}, env...),
+		PortBindings: map[docker.Port][]docker.PortBinding{},
+		ExposedPorts: []string{},
+		Labels:       resourceLabels(session.id, component, serviceNames(conf)...),
+	}
+
+	if conf.RunOpts != nil {
+		runOpts.Cmd = conf.RunOpts.Cmd
+		runOpts.Entrypoint = conf.RunOpts.Entrypoint
+		runOpts.User = conf.RunOpts.User
+		runOpts.WorkingDir = conf.RunOpts.WorkingDir
+		runOpts.Hostname = conf.RunOpts.Hostname
+		runOpts.ExtraHosts = conf.RunOpts.ExtraHosts
+		runOpts.CapAdd = conf.RunOpts.CapAdd
+		runOpts.Platform = conf.RunOpts.Platform
+	}
+
+	hostPorts := map[string]string{}
+	for serviceName, e := range containerEndpoints(conf) {
+		runOpts.ExposedPorts = append(runOpts.ExposedPorts, e.portSpec())
+
+		if envFunc, ok := conf.PreassignedHostPorts[serviceName]; ok {
+			port, err := freeHostPort()
+			if err != nil {
+				return nil, nil, fmt.Errorf("can not obtain free port for service %s: %w", serviceName, err)
+			}
+			runOpts.Env = append(runOpts.Env, envFunc(session.HostAddress(), port)...)
+			if !session.inDocker {
+				// The container listens on the host port as well, e.g. Kafka's outside listener.
+				runOpts.ExposedPorts = append(runOpts.ExposedPorts, port+"/tcp")
+				publishPort(runOpts, Endpoint{Port: port, Protocol: TCP}, port)
+				hostPorts[serviceName] = port
+			}
+			continue
+		}
+
+		if session.inDocker {
+			continue
+		}
+
+		// staticPort means that we should map this port 1 to 1 on the host,
+		// trusting that the component has obtained a free one.
+		if staticPort, ok := conf.StaticServicePorts[serviceName]; ok {
+			if staticPort != e.Port {
+				runOpts.ExposedPorts = append(runOpts.ExposedPorts, staticPort+"/tcp")
+			}
+			publishPort(runOpts, Endpoint{Port: staticPort, Protocol: TCP}, staticPort)
+			hostPorts[serviceName] = staticPort
+			continue
+		}
+
+		// by default Docker assigns a free host port, read back once the container has started.
+		publishPort(runOpts, e, "")
+	}
+
+	return runOpts, hostPorts, nil
+}
+
+// publishPort binds the port of an endpoint to a host port, an empty host port lets Docker assign a free one.
+// The port must be exposed by the caller.
+func publishPort(runOpts *dockertest.RunOptions, e Endpoint, hostPort string) {
+	runOpts.PortBindings[docker.Port(e.portSpec())] = []docker.PortBinding{
+		{HostIP: "0.0.0.0", HostPort: hostPort},
+	}
+}
+
+// boundHostPort reads the host port Docker bound to the port of an endpoint.
+func boundHostPort(container *docker.Container, e Endpoint) (string, error) {
+	if container.NetworkSettings == nil {
+		return "", fmt.Errorf("container %s has no network settings", container.Name)
+	}
+	for _, binding := range container.NetworkSettings.Ports[docker.Port(e.portSpec())] {
+		if binding.HostPort != "" {
+			return binding.HostPort, nil
+		}
+	}
+	return "", fmt.Errorf("port %s of container %s is not published", e.portSpec(), container.Name)
+}
+
+// isPortConflict reports whether starting a container failed because a host port was taken.
+func isPortConflict(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "port is already allocated") || strings.Contains(msg, "address already in use")
+}
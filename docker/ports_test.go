@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerRunOptionsPorts(t *testing.T) {
+	conf := SimpleContainerConfig{
+		Name: "kafka",
+		ServicePorts: map[string]string{
+			"kafka":     "9092",
+			"zookeeper": "2181",
+			"static":    "80",
+		},
+		StaticServicePorts: map[string]string{"static": "8080"},
+		PreassignedHostPorts: map[string]func(string, string) []string{
+			"kafka": func(host, port string) []string { return []string{"ADDR=" + host + ":" + port} },
+		},
+	}
+
+	runOpts, hostPorts, err := containerRunOptions(&Session{id: "000", hostAddress: "10.0.0.2"}, "kafka", conf,
+		[]string{"FOO=bar"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "000-kafka", runOpts.Name)
+	require.Len(t, runOpts.Env, 2)
+	assert.Equal(t, "FOO=bar", runOpts.Env[0])
+	assert.Equal(t, "ADDR=10.0.0.2:"+hostPorts["kafka"], runOpts.Env[1])
+
+	assert.Equal(t, "8080", hostPorts["static"])
+	assert.Equal(t, []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: "8080"}}, runOpts.PortBindings["8080/tcp"])
+	assert.Equal(t, []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPorts["kafka"]}},
+		runOpts.PortBindings[docker.Port(hostPorts["kafka"]+"/tcp")])
+
+	// Docker assigns the host port of the remaining services.
+	_, ok := hostPorts["zookeeper"]
+	assert.False(t, ok)
+	assert.Equal(t, []docker.PortBinding{{HostIP: "0.0.0.0"}}, runOpts.PortBindings["2181/tcp"])
+
+	// Every port is exposed once, along with the container ports bound 1 to 1.
+	assert.ElementsMatch(t, []string{"9092/tcp", hostPorts["kafka"] + "/tcp", "2181/tcp", "80/tcp", "8080/tcp"},
+		runOpts.ExposedPorts)
+}
+
+func TestContainerRunOptionsInDocker(t *testing.T) {
+	conf := SimpleContainerConfig{
+		Name:         "redis",
+		ServicePorts: map[string]string{"redis": "6379"},
+	}
+
+	runOpts, hostPorts, err := containerRunOptions(&Session{id: "000", inDocker: true}, "redis", conf, nil)
+	require.NoError(t, err)
+
+	assert.Empty(t, hostPorts)
+	assert.Empty(t, runOpts.PortBindings)
+	assert.Equal(t, []string{"6379/tcp"}, runOpts.ExposedPorts)
+}
+
+func TestBoundHostPort(t *testing.T) {
+	c := &docker.Container{
+		Name: "000-redis",
+		NetworkSettings: &docker.NetworkSettings{
+			Ports: map[docker.Port][]docker.PortBinding{
+				"6379/tcp": {{HostIP: "0.0.0.0", HostPort: "49153"}, {HostIP: "::", HostPort: "49153"}},
+				"6831/udp": {{HostIP: "0.0.0.0", HostPort: "49154"}},
+			},
+		},
+	}
+
+	port, err := boundHostPort(c, Endpoint{Port: "6379"})
+	require.NoError(t, err)
+	assert.Equal(t, "49153", port)
+
+	port, err = boundHostPort(c, Endpoint{Port: "6831", Protocol: UDP})
+	require.NoError(t, err)
+	assert.Equal(t, "49154", port)
+
+	_, err = boundHostPort(c, Endpoint{Port: "80"})
+	assert.EqualError(t, err, "port 80/tcp of container 000-redis is not published")
+	_, err = boundHostPort(c, Endpoint{Port: "6379", Protocol: UDP})
+	assert.EqualError(t, err, "port 6379/udp of container 000-redis is not published")
+}
+
+func TestIsPortConflict(t *testing.T) {
+	assert.True(t, isPortConflict(errors.New("Bind for 0.0.0.0:9092 failed: port is already allocated")))
+	assert.False(t, isPortConflict(errors.New("no such image")))
+}
@@ -0,0 +1,217 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+	"golang.org/x/sync/errgroup"
+)
+
+// PullPolicy decides when the images of a session are pulled.
+type PullPolicy int
+
+const (
+	// PullIfMissing pulls the images which are not present locally.
+	PullIfMissing PullPolicy = iota
+	// PullAlways pulls every image once per session, to pick up updates of mutable tags.
+	PullAlways
+	// PullNever never pulls images, a missing image fails the start. It is the offline mode.
+	PullNever
+)
+
+func (p PullPolicy) String() string {
+	switch p {
+	case PullIfMissing:
+		return "if-missing"
+	case PullAlways:
+		return "always"
+	case PullNever:
+		return "never"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePullPolicy parses a pull policy, as used by the BAKE_PULL_POLICY env var.
+func ParsePullPolicy(s string) (PullPolicy, error) {
+	for _, p := range []PullPolicy{PullIfMissing, PullAlways, PullNever} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return PullIfMissing, fmt.Errorf("unknown pull policy %q, expected always, if-missing or never", s)
+}
+
+// WithPullPolicy sets the pull policy of the session. It can also be set with the BAKE_PULL_POLICY env var.
+func WithPullPolicy(policy PullPolicy) SessionOptionFunc {
+	return func(s *Session) {
+		s.pullPolicy = policy
+	}
+}
+
+// pullPolicyFromEnv reads the pull policy from the BAKE_PULL_POLICY env var, defaulting to PullIfMissing.
+func pullPolicyFromEnv() (PullPolicy, error) {
+	v := os.Getenv("BAKE_PULL_POLICY")
+	if v == "" {
+		return PullIfMissing, nil
+	}
+	return ParsePullPolicy(v)
+}
+
+// ImageRef references an image of a container.
+type ImageRef struct {
+	Repository string
+	Tag        string
+	// Platform selects the image platform, e.g. "linux/amd64", empty means the daemon's platform.
+	Platform string
+	// Digest pins the image, e.g. "sha256:...", it takes precedence over the tag.
+	Digest string
+	// Auth holds the credentials for pulling the image, the ones of the Docker config are used when it is nil.
+	Auth *docker.AuthConfiguration
+}
+
+// String returns the tag reference of the image, e.g. "redis:7-alpine".
+func (r ImageRef) String() string {
+	return r.Repository + ":" + r.tag()
+}
+
+// Reference returns the digest reference of the image if it is pinned, the tag reference otherwise.
+func (r ImageRef) Reference() string {
+	if r.Digest != "" {
+		return r.Repository + "@" + r.Digest
+	}
+	return r.String()
+}
+
+func (r ImageRef) tag() string {
+	if r.Tag == "" {
+		return "latest"
+	}
+	return r.Tag
+}
+
+// ImageLister is implemented by components which can list the images they run, so that they are pulled
+// before any component starts.
+type ImageLister interface {
+	Images() []ImageRef
+}
+
+// PrefetchImages applies the session pull policy to the images of the components in parallel. In offline mode it
+// fails up front with the list of missing images.
+func (s *Session) PrefetchImages(ctx context.Context, cs ...ContextComponent) error {
+	var listers []ImageLister
+	for _, c := range cs {
+		if lister, ok := c.(ImageLister); ok {
+			listers = append(listers, lister)
+		}
+	}
+	images := listImages(listers)
+	if len(images) == 0 {
+		return nil
+	}
+	for i, img := range images {
+		resolved, err := s.resolveImage(img)
+		if err != nil {
+			return err
+		}
+		images[i] = resolved
+	}
+
+	rt, err := s.Runtime()
+	if err != nil {
+		return err
+	}
+
+	if s.pullPolicy == PullNever {
+		return checkImagesPresent(ctx, rt, images)
+	}
+
+	var done int
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	for _, img := range images {
+		g.Go(func() error {
+			start := time.Now()
+			pulled, err := s.pullImage(ctx, rt, img)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if pulled {
+				fmt.Printf("Pulled image %s (%d/%d) in %s\n", img.Reference(), done, len(images),
+					time.Since(start).Round(time.Millisecond))
+			} else {
+				fmt.Printf("Image %s is present (%d/%d)\n", img.Reference(), done, len(images))
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// resolveImage pins an image to the digest of the session lock, if any, and applies the session rewrite rules.
+func (s *Session) resolveImage(img ImageRef) (ImageRef, error) {
+	img, err := s.lockedImage(img)
+	if err != nil {
+		return img, err
+	}
+	img.Repository = rewriteRepository(img.Repository, s.imageRewrites)
+	return img, nil
+}
+
+// pullImage applies the session pull policy to an image and reports whether it was pulled.
+func (s *Session) pullImage(ctx context.Context, rt Runtime, img ImageRef) (bool, error) {
+	switch s.pullPolicy {
+	case PullNever:
+		return false, checkImagesPresent(ctx, rt, []ImageRef{img})
+	case PullAlways:
+		if _, pulled := s.pulledImages.LoadOrStore(img, true); pulled {
+			return false, nil
+		}
+	default:
+		_, err := rt.InspectImage(ctx, img.Reference())
+		if err == nil {
+			return false, nil
+		}
+		if !errors.Is(err, docker.ErrNoSuchImage) {
+			return false, fmt.Errorf("inspect image %s: %w", img.Reference(), err)
+		}
+	}
+
+	fmt.Printf("Pulling image %s\n", img.Reference())
+	if err := rt.PullImage(ctx, img); err != nil {
+		s.pulledImages.Delete(img)
+		return false, fmt.Errorf("pull image %s: %w", img.Reference(), err)
+	}
+	return true, nil
+}
+
+// checkImagesPresent fails with the list of images missing locally.
+func checkImagesPresent(ctx context.Context, rt Runtime, images []ImageRef) error {
+	var missing []string
+	for _, img := range images {
+		_, err := rt.InspectImage(ctx, img.Reference())
+		if errors.Is(err, docker.ErrNoSuchImage) {
+			missing = append(missing, img.Reference())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("inspect image %s: %w", img.Reference(), err)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("offline mode, missing images must be loaded with docker load: %s", strings.Join(missing, ", "))
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePullPolicy(t *testing.T) {
+	for _, p := range []PullPolicy{PullIfMissing, PullAlways, PullNever} {
+		got, err := ParsePullPolicy(p.String())
+		require.NoError(t, err)
+		assert.Equal(t, p, got)
+	}
+
+	_, err := ParsePullPolicy("sometimes")
+	assert.EqualError(t, err, `unknown pull policy "sometimes", expected always, if-missing or never`)
+}
+
+func TestNewSessionPullPolicy(t *testing.T) {
+	t.Setenv("BAKE_PULL_POLICY", "never")
+	s, err := NewSession("000", "abc")
+	require.NoError(t, err)
+	assert.Equal(t, PullNever, s.pullPolicy)
+
+	s, err = NewSession("000", "abc", WithPullPolicy(PullAlways))
+	require.NoError(t, err)
+	assert.Equal(t, PullAlways, s.pullPolicy)
+
+	t.Setenv("BAKE_PULL_POLICY", "sometimes")
+	_, err = NewSession("000", "abc")
+	assert.Error(t, err)
+}
+
+func TestSimpleComponentImages(t *testing.T) {
+	c := &SimpleComponent{
+		Name: "kafka",
+		Containers: []SimpleContainerConfig{
+			{Name: "zookeeper", Repository: "wurstmeister/zookeeper"},
+			{Name: "kafka", Repository: "wurstmeister/kafka", Tag: "2.13", RunOpts: &RunOptions{Platform: "linux/amd64"}},
+			{Name: "service", BuildOpts: &BuildOptions{Dockerfile: "Dockerfile"}},
+		},
+	}
+
+	images := c.Images()
+	assert.Equal(t, []ImageRef{
+		{Repository: "wurstmeister/zookeeper"},
+		{Repository: "wurstmeister/kafka", Tag: "2.13", Platform: "linux/amd64"},
+	}, images)
+	assert.Equal(t, "wurstmeister/zookeeper:latest", images[0].String())
+}
+
+func TestPrefetchImagesWithoutImages(t *testing.T) {
+	s := newTestSession()
+	require.NoError(t, s.PrefetchImages(context.Background(), &SimpleComponent{Name: "service"}))
+}
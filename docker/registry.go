@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+const (
+	dockerHubRegistry = "docker.io"
+	// dockerHubAuthKey is the key of Docker Hub credentials in the Docker config file.
+	dockerHubAuthKey = "https://index.docker.io/v1/"
+)
+
+// ImageRewriteRule rewrites the repository prefix of images, e.g. to pull from a mirror.
+// Repositories are matched in their fully qualified form, e.g. "docker.io/library/redis" for "redis".
+type ImageRewriteRule struct {
+	From string
+	To   string
+}
+
+// WithImageRewrite rewrites the repositories of all session images starting with a prefix,
+// e.g. from "docker.io/" to "mirror.example.com/dockerhub/". The first matching rule applies.
+// Rules can also be set with the BAKE_IMAGE_REWRITE env var, as comma separated from=to pairs,
+// which take precedence over the ones of this option.
+func WithImageRewrite(from, to string) SessionOptionFunc {
+	return func(s *Session) {
+		s.imageRewrites = append(s.imageRewrites, ImageRewriteRule{From: from, To: to})
+	}
+}
+
+// WithRegistryAuth sets the credentials for pulling a private image in a SimpleContainerConfig.
+// Without them the credentials of the Docker config file are used.
+func WithRegistryAuth(username, password, serverAddress string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.RegistryAuth = &docker.AuthConfiguration{
+			Username:      username,
+			Password:      password,
+			ServerAddress: serverAddress,
+		}
+	}
+}
+
+// imageRewritesFromEnv parses the rules of the BAKE_IMAGE_REWRITE env var.
+func imageRewritesFromEnv() ([]ImageRewriteRule, error) {
+	v := os.Getenv("BAKE_IMAGE_REWRITE")
+	if v == "" {
+		return nil, nil
+	}
+
+	var rules []ImageRewriteRule
+	for _, pair := range strings.Split(v, ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid image rewrite rule %q, expected from=to", pair)
+		}
+		rules = append(rules, ImageRewriteRule{From: from, To: to})
+	}
+	return rules, nil
+}
+
+// rewriteRepository applies the first matching rule to a repository, which is returned as is if none matches.
+func rewriteRepository(repository string, rules []ImageRewriteRule) string {
+	qualified := qualifiedRepository(repository)
+	for _, r := range rules {
+		if strings.HasPrefix(qualified, r.From) {
+			return r.To + strings.TrimPrefix(qualified, r.From)
+		}
+	}
+	return repository
+}
+
+// qualifiedRepository returns the fully qualified form of a repository, e.g. "docker.io/library/redis" for "redis".
+func qualifiedRepository(repository string) string {
+	if registryHost(repository) != dockerHubRegistry || strings.HasPrefix(repository, dockerHubRegistry+"/") {
+		return repository
+	}
+	if !strings.Contains(repository, "/") {
+		return dockerHubRegistry + "/library/" + repository
+	}
+	return dockerHubRegistry + "/" + repository
+}
+
+// registryHost returns the registry of a repository, Docker Hub unless the first path component is a host.
+func registryHost(repository string) string {
+	first, _, ok := strings.Cut(repository, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return dockerHubRegistry
+}
+
+// pullAuth returns the credentials for pulling an image, the explicit ones or else the ones of the Docker config.
+func pullAuth(img ImageRef) docker.AuthConfiguration {
+	if img.Auth != nil {
+		return *img.Auth
+	}
+
+	host := registryHost(img.Repository)
+	if auths, err := docker.NewAuthConfigurationsFromDockerCfg(); err == nil {
+		keys := []string{host, "https://" + host}
+		if host == dockerHubRegistry {
+			keys = append(keys, dockerHubAuthKey)
+		}
+		for _, key := range keys {
+			if auth, ok := auths.Configs[key]; ok {
+				return auth
+			}
+		}
+	}
+	if auth, err := docker.NewAuthConfigurationsFromCredsHelpers(host); err == nil {
+		return *auth
+	}
+	return docker.AuthConfiguration{}
+}
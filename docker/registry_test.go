@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteRepository(t *testing.T) {
+	rules := []ImageRewriteRule{
+		{From: "docker.io/library/", To: "mirror.example.com/hub/"},
+		{From: "docker.io/", To: "mirror.example.com/"},
+	}
+
+	assert.Equal(t, "mirror.example.com/hub/redis", rewriteRepository("redis", rules))
+	assert.Equal(t, "mirror.example.com/mockserver/mockserver", rewriteRepository("mockserver/mockserver", rules))
+	assert.Equal(t, "mirror.example.com/hub/mongo", rewriteRepository("docker.io/library/mongo", rules))
+	assert.Equal(t, "ghcr.io/beatlabs/bake", rewriteRepository("ghcr.io/beatlabs/bake", rules))
+	assert.Equal(t, "redis", rewriteRepository("redis", nil))
+}
+
+func TestRegistryHost(t *testing.T) {
+	assert.Equal(t, "docker.io", registryHost("redis"))
+	assert.Equal(t, "docker.io", registryHost("wurstmeister/kafka"))
+	assert.Equal(t, "ghcr.io", registryHost("ghcr.io/beatlabs/bake"))
+	assert.Equal(t, "localhost:5000", registryHost("localhost:5000/redis"))
+	assert.Equal(t, "localhost", registryHost("localhost/redis"))
+}
+
+func TestImageRewritesFromEnv(t *testing.T) {
+	t.Setenv("BAKE_IMAGE_REWRITE", "docker.io/=mirror.example.com/, ghcr.io/=mirror.example.com/ghcr/")
+	rules, err := imageRewritesFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []ImageRewriteRule{
+		{From: "docker.io/", To: "mirror.example.com/"},
+		{From: "ghcr.io/", To: "mirror.example.com/ghcr/"},
+	}, rules)
+
+	s, err := NewSession("000", "abc", WithImageRewrite("quay.io/", "mirror.example.com/quay/"))
+	require.NoError(t, err)
+	assert.Len(t, s.imageRewrites, 3)
+
+	t.Setenv("BAKE_IMAGE_REWRITE", "docker.io/")
+	_, err = imageRewritesFromEnv()
+	assert.EqualError(t, err, `invalid image rewrite rule "docker.io/", expected from=to`)
+}
+
+func TestResolveImage(t *testing.T) {
+	s := newTestSession()
+	s.imageLock = &ImageLock{Images: map[string]string{"redis:7-alpine": "redis@sha256:abc"}}
+	s.imageRewrites = []ImageRewriteRule{{From: "docker.io/library/", To: "mirror.example.com/"}}
+
+	img, err := s.resolveImage(ImageRef{Repository: "redis", Tag: "7-alpine"})
+	require.NoError(t, err)
+	assert.Equal(t, "mirror.example.com/redis@sha256:abc", img.Reference())
+}
+
+func TestPullAuthExplicit(t *testing.T) {
+	auth := &docker.AuthConfiguration{Username: "bake", Password: "secret", ServerAddress: "registry.example.com"}
+	assert.Equal(t, *auth, pullAuth(ImageRef{Repository: "registry.example.com/private", Auth: auth}))
+
+	conf := SimpleContainerConfig{Repository: "registry.example.com/private"}
+	WithRegistryAuth("bake", "secret", "registry.example.com")(&conf)
+	assert.Equal(t, auth, containerImage(conf).Auth)
+}
@@ -0,0 +1,133 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rollbackTimeout bounds the removal of resources after a failed start.
+const rollbackTimeout = time.Minute
+
+// createdNetworks holds the IDs of the networks bake created in this process,
+// so that sessions using them can remove them on rollback.
+var createdNetworks sync.Map
+
+type resourceKind int
+
+const (
+	containerResource resourceKind = iota
+	imageResource
+	volumeResource
+	networkResource
+)
+
+func (k resourceKind) String() string {
+	switch k {
+	case containerResource:
+		return "container"
+	case imageResource:
+		return "image"
+	case volumeResource:
+		return "volume"
+	case networkResource:
+		return "network"
+	default:
+		return "unknown"
+	}
+}
+
+type trackedResource struct {
+	kind resourceKind
+	id   string
+}
+
+// trackResource records a resource created by the session, so that it can be rolled back.
+func (s *Session) trackResource(kind resourceKind, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resources = append(s.resources, trackedResource{kind: kind, id: id})
+}
+
+// resourceCheckpoint marks the current point of the created resources log.
+func (s *Session) resourceCheckpoint() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.resources)
+}
+
+// rollback removes the resources created after the checkpoint, most recent first.
+// The session network is removed as well if the session owns it and no earlier containers use it.
+func (s *Session) rollback(ctx context.Context, checkpoint int) error {
+	s.mu.Lock()
+	created := append([]trackedResource{}, s.resources[checkpoint:]...)
+	s.resources = s.resources[:checkpoint]
+	removeNetwork := s.ownsNetwork && !hasContainers(s.resources)
+	s.mu.Unlock()
+
+	if removeNetwork {
+		created = append(created, trackedResource{kind: networkResource, id: s.networkID})
+	}
+	if len(created) == 0 {
+		return nil
+	}
+
+	// Containers go first as they hold references to images and networks.
+	var errs []error
+	for _, kind := range []resourceKind{containerResource, imageResource, volumeResource, networkResource} {
+		for i := len(created) - 1; i >= 0; i-- {
+			r := created[i]
+			if r.kind != kind {
+				continue
+			}
+			fmt.Printf("Rolling back %s: %s\n", r.kind, r.id)
+			if err := s.removeTracked(ctx, r); err != nil {
+				errs = append(errs, fmt.Errorf("remove %s %s: %w", r.kind, r.id, err))
+				continue
+			}
+			if r.kind == networkResource {
+				s.forgetNetwork(r.id)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func hasContainers(resources []trackedResource) bool {
+	for _, r := range resources {
+		if r.kind == containerResource {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTracked removes a resource of the session through the session runtime.
+func (s *Session) removeTracked(ctx context.Context, r trackedResource) error {
+	rt, err := s.Runtime()
+	if err != nil {
+		return err
+	}
+	return removeResource(ctx, rt, r)
+}
+
+// removeResource removes a resource, missing resources are not considered an error.
+func removeResource(ctx context.Context, rt Runtime, r trackedResource) error {
+	switch r.kind {
+	case containerResource:
+		return rt.RemoveContainer(ctx, r.id)
+	case imageResource:
+		return rt.RemoveImage(ctx, r.id)
+	case volumeResource:
+		return rt.RemoveVolume(ctx, r.id)
+	case networkResource:
+		return rt.RemoveNetwork(ctx, r.id)
+	default:
+		return fmt.Errorf("unknown resource kind %d", r.kind)
+	}
+}
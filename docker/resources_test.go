@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type trackingComponent struct {
+	fakeComponent
+	resourceID string
+}
+
+func (c trackingComponent) StartContext(_ context.Context, s *Session) error {
+	s.trackResource(containerResource, c.resourceID)
+	return c.err
+}
+
+func TestStartComponentsKeepOnFailure(t *testing.T) {
+	errBoom := errors.New("boom")
+	sess := newTestSession()
+	sess.keepOnFailure = true
+
+	err := sess.StartComponentsContext(context.Background(),
+		trackingComponent{fakeComponent: fakeComponent{name: "redis", err: errBoom}, resourceID: "abc"})
+	require.ErrorIs(t, err, errBoom)
+	assert.Equal(t, []trackedResource{{kind: containerResource, id: "abc"}}, sess.resources)
+}
+
+func TestRollbackNothingCreated(t *testing.T) {
+	sess := newTestSession()
+	sess.trackResource(containerResource, "abc")
+
+	err := sess.rollback(context.Background(), sess.resourceCheckpoint())
+	require.NoError(t, err)
+	assert.Len(t, sess.resources, 1)
+}
+
+func TestNewSessionKeepOnFailure(t *testing.T) {
+	sess, err := NewSession("000", "net")
+	require.NoError(t, err)
+	assert.False(t, sess.keepOnFailure)
+
+	sess, err = NewSession("000", "net", WithKeepOnFailure())
+	require.NoError(t, err)
+	assert.True(t, sess.keepOnFailure)
+
+	t.Setenv("BAKE_KEEP_ON_FAILURE", "true")
+	sess, err = NewSession("000", "net")
+	require.NoError(t, err)
+	assert.True(t, sess.keepOnFailure)
+}
+
+func TestNewSessionOwnsNetwork(t *testing.T) {
+	createdNetworks.Store("owned-net", struct{}{})
+	defer createdNetworks.Delete("owned-net")
+
+	sess, err := NewSession("000", "owned-net")
+	require.NoError(t, err)
+	assert.True(t, sess.ownsNetwork)
+
+	sess, err = NewSession("000", "other-net")
+	require.NoError(t, err)
+	assert.False(t, sess.ownsNetwork)
+}
+
+func TestStartComponentsRollbackFakeRuntime(t *testing.T) {
+	tests := map[string]struct {
+		opts []SessionOptionFunc
+		kept bool
+	}{
+		"rollback":        {},
+		"keep on failure": {opts: []SessionOptionFunc{WithKeepOnFailure()}, kept: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			rt := NewFakeRuntime()
+			sess := newFakeSession(t, rt, tt.opts...)
+
+			mongo := &SimpleComponent{
+				Name: "mongo",
+				Containers: []SimpleContainerConfig{{
+					Name:         "mongo",
+					Repository:   "mongo",
+					Tag:          "7",
+					ServicePorts: map[string]string{"mongo": "27017"},
+					Mounts:       []Mount{{Type: VolumeMount, Source: "data", Target: "/data/db"}},
+				}},
+			}
+			service := &SimpleComponent{
+				Name: "service",
+				Containers: []SimpleContainerConfig{{
+					Name:         "service",
+					Repository:   "service",
+					Tag:          "latest",
+					ServicePorts: map[string]string{"service": "8080"},
+					DependsOn:    []string{"mongo"},
+					ReadyContextFunc: func(context.Context, *Session) error {
+						return errors.New("not ready")
+					},
+				}},
+			}
+
+			err := sess.StartComponentsContext(ctx, mongo, service)
+			require.Error(t, err)
+
+			for _, container := range []string{"000-mongo", "000-service"} {
+				_, err = rt.InspectContainer(ctx, container)
+				assert.Equal(t, tt.kept, err == nil, container)
+			}
+			_, err = rt.InspectVolume(ctx, "000-data")
+			assert.Equal(t, tt.kept, err == nil)
+			_, err = rt.InspectNetwork(ctx, sess.NetworkID())
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// Runtime runs the containers and networks of a session. Docker is the default runtime, another one can be set with
+// WithRuntime, e.g. a FakeRuntime to unit test components without a daemon.
+// Image builds always go through the Docker client of the session.
+type Runtime interface {
+	// RunContainer creates and starts a container and returns it inspected. The container is returned alongside any
+	// error once it has been created, so that callers can remove it.
+	RunContainer(ctx context.Context, opts docker.CreateContainerOptions) (*docker.Container, error)
+	// InspectContainer returns a container by ID or name.
+	InspectContainer(ctx context.Context, id string) (*docker.Container, error)
+	// ListContainers lists all containers, including stopped ones, matching the filters, e.g. by label.
+	ListContainers(ctx context.Context, filters map[string][]string) ([]docker.APIContainers, error)
+	// WaitContainer blocks until a container stops and returns its exit code.
+	WaitContainer(ctx context.Context, id string) (int, error)
+	// ExecContainer runs a command inside a running container and returns its exit code.
+	ExecContainer(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error)
+	// ContainerLogs writes the stdout and stderr logs of a container to w.
+	ContainerLogs(ctx context.Context, id string, w io.Writer, opts LogOptions) error
+	// RemoveContainer force removes a container, along with its anonymous volumes.
+	// Missing containers are not considered an error.
+	RemoveContainer(ctx context.Context, id string) error
+	// InspectImage returns a local image, or docker.ErrNoSuchImage if it is missing.
+	InspectImage(ctx context.Context, ref string) (*docker.Image, error)
+	// PullImage pulls an image from its registry.
+	PullImage(ctx context.Context, img ImageRef) error
+	// ListImages lists the local images matching the filters, e.g. by label.
+	ListImages(ctx context.Context, filters map[string][]string) ([]docker.APIImages, error)
+	// RemoveImage force removes an image by ID or reference. Missing images are not considered an error.
+	RemoveImage(ctx context.Context, id string) error
+	// CreateNetwork creates a labeled network and returns its ID.
+	CreateNetwork(ctx context.Context, name string, labels map[string]string) (string, error)
+	// InspectNetwork returns a network by ID or name.
+	InspectNetwork(ctx context.Context, id string) (*docker.Network, error)
+	// ListNetworks lists the networks matching the filters, e.g. by label.
+	ListNetworks(ctx context.Context, filters map[string][]string) ([]docker.Network, error)
+	// RemoveNetwork removes a network. Missing networks are not considered an error.
+	RemoveNetwork(ctx context.Context, id string) error
+	// InspectVolume returns a volume by name, or docker.ErrNoSuchVolume if it is missing.
+	InspectVolume(ctx context.Context, name string) (*docker.Volume, error)
+	// CreateVolume creates a labeled volume.
+	CreateVolume(ctx context.Context, name string, labels map[string]string) error
+	// ListVolumes lists the volumes matching the filters, e.g. by label.
+	ListVolumes(ctx context.Context, filters map[string][]string) ([]docker.Volume, error)
+	// RemoveVolume force removes a volume. Missing volumes are not considered an error.
+	RemoveVolume(ctx context.Context, name string) error
+}
+
+// WithRuntime runs the containers and networks of the session on the runtime instead of Docker.
+func WithRuntime(rt Runtime) SessionOptionFunc {
+	return func(s *Session) {
+		s.runtime = rt
+	}
+}
+
+// NewDockerRuntime returns the Runtime backed by a Docker client.
+func NewDockerRuntime(client *docker.Client) Runtime {
+	return dockerRuntime{client: client}
+}
+
+type dockerRuntime struct {
+	client *docker.Client
+}
+
+func (r dockerRuntime) RunContainer(ctx context.Context, opts docker.CreateContainerOptions) (*docker.Container, error) {
+	opts.Context = ctx
+	// Daemons before API 1.44 attach a new container to a single network, the others are connected before it starts.
+	var connect map[string]*docker.EndpointConfig
+	if opts.NetworkingConfig != nil && len(opts.NetworkingConfig.EndpointsConfig) > 1 {
+		ids := make([]string, 0, len(opts.NetworkingConfig.EndpointsConfig))
+		for id := range opts.NetworkingConfig.EndpointsConfig {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		endpoints := opts.NetworkingConfig.EndpointsConfig
+		opts.NetworkingConfig = &docker.NetworkingConfig{
+			EndpointsConfig: map[string]*docker.EndpointConfig{ids[0]: endpoints[ids[0]]},
+		}
+		connect = map[string]*docker.EndpointConfig{}
+		for _, id := range ids[1:] {
+			connect[id] = endpoints[id]
+		}
+	}
+
+	c, err := r.client.CreateContainer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, endpoint := range connect {
+		err := r.client.ConnectNetwork(id, docker.NetworkConnectionOptions{
+			Container:      c.ID,
+			EndpointConfig: endpoint,
+			Context:        ctx,
+		})
+		if err != nil {
+			return c, fmt.Errorf("connect network %s: %w", id, err)
+		}
+	}
+
+	if err := r.client.StartContainerWithContext(c.ID, nil, ctx); err != nil {
+		return c, err
+	}
+
+	inspected, err := r.client.InspectContainerWithContext(c.ID, ctx)
+	if err != nil {
+		return c, err
+	}
+	return inspected, nil
+}
+
+func (r dockerRuntime) InspectContainer(ctx context.Context, id string) (*docker.Container, error) {
+	return r.client.InspectContainerWithContext(id, ctx)
+}
+
+func (r dockerRuntime) ListContainers(ctx context.Context, filters map[string][]string) ([]docker.APIContainers, error) {
+	return r.client.ListContainers(docker.ListContainersOptions{All: true, Filters: filters, Context: ctx})
+}
+
+func (r dockerRuntime) WaitContainer(ctx context.Context, id string) (int, error) {
+	return r.client.WaitContainerWithContext(id, ctx)
+}
+
+func (r dockerRuntime) ExecContainer(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer,
+) (int, error) {
+	exec, err := r.client.CreateExec(docker.CreateExecOptions{
+		Container:    id,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+		Context:      ctx,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("create exec: %w", err)
+	}
+
+	err = r.client.StartExec(exec.ID, docker.StartExecOptions{
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+		Context:      ctx,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("start exec: %w", err)
+	}
+
+	inspect, err := r.client.InspectExec(exec.ID)
+	if err != nil {
+		return -1, fmt.Errorf("inspect exec: %w", err)
+	}
+
+	return inspect.ExitCode, nil
+}
+
+func (r dockerRuntime) ContainerLogs(ctx context.Context, id string, w io.Writer, opts LogOptions) error {
+	logsOpts := docker.LogsOptions{
+		Context:      ctx,
+		Container:    id,
+		OutputStream: w,
+		ErrorStream:  w,
+		Stdout:       true,
+		Stderr:       true,
+		Follow:       opts.Follow,
+		Timestamps:   opts.Timestamps,
+		Tail:         "all",
+	}
+	if opts.Tail > 0 {
+		logsOpts.Tail = strconv.Itoa(opts.Tail)
+	}
+	if !opts.Since.IsZero() {
+		logsOpts.Since = opts.Since.Unix()
+	}
+	return r.client.Logs(logsOpts)
+}
+
+func (r dockerRuntime) RemoveContainer(ctx context.Context, id string) error {
+	err := r.client.RemoveContainer(docker.RemoveContainerOptions{
+		ID:            id,
+		Force:         true,
+		RemoveVolumes: true,
+		Context:       ctx,
+	})
+	var noSuchContainer *docker.NoSuchContainer
+	if errors.As(err, &noSuchContainer) {
+		return nil
+	}
+	return err
+}
+
+func (r dockerRuntime) InspectImage(_ context.Context, ref string) (*docker.Image, error) {
+	return r.client.InspectImage(ref)
+}
+
+func (r dockerRuntime) PullImage(ctx context.Context, img ImageRef) error {
+	tag := img.tag()
+	if img.Digest != "" {
+		tag = img.Digest
+	}
+	return r.client.PullImage(docker.PullImageOptions{
+		Repository: img.Repository,
+		Tag:        tag,
+		Platform:   img.Platform,
+		Context:    ctx,
+	}, pullAuth(img))
+}
+
+func (r dockerRuntime) CreateNetwork(ctx context.Context, name string, labels map[string]string) (string, error) {
+	n, err := r.client.CreateNetwork(docker.CreateNetworkOptions{Name: name, Labels: labels, Context: ctx})
+	if err != nil {
+		return "", err
+	}
+	return n.ID, nil
+}
+
+func (r dockerRuntime) InspectNetwork(_ context.Context, id string) (*docker.Network, error) {
+	return r.client.NetworkInfo(id)
+}
+
+func (r dockerRuntime) ListNetworks(_ context.Context, filters map[string][]string) ([]docker.Network, error) {
+	opts := docker.NetworkFilterOpts{}
+	for key, values := range filters {
+		opts[key] = map[string]bool{}
+		for _, v := range values {
+			opts[key][v] = true
+		}
+	}
+	return r.client.FilteredListNetworks(opts)
+}
+
+func (r dockerRuntime) RemoveNetwork(_ context.Context, id string) error {
+	err := r.client.RemoveNetwork(id)
+	var noSuchNetwork *docker.NoSuchNetwork
+	if errors.As(err, &noSuchNetwork) {
+		return nil
+	}
+	return err
+}
+
+func (r dockerRuntime) InspectVolume(_ context.Context, name string) (*docker.Volume, error) {
+	return r.client.InspectVolume(name)
+}
+
+func (r dockerRuntime) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	_, err := r.client.CreateVolume(docker.CreateVolumeOptions{Name: name, Labels: labels, Context: ctx})
+	return err
+}
+
+func (r dockerRuntime) ListVolumes(ctx context.Context, filters map[string][]string) ([]docker.Volume, error) {
+	return r.client.ListVolumes(docker.ListVolumesOptions{Filters: filters, Context: ctx})
+}
+
+func (r dockerRuntime) ListImages(ctx context.Context, filters map[string][]string) ([]docker.APIImages, error) {
+	return r.client.ListImages(docker.ListImagesOptions{Filters: filters, Context: ctx})
+}
+
+func (r dockerRuntime) RemoveImage(ctx context.Context, id string) error {
+	err := r.client.RemoveImageExtended(id, docker.RemoveImageOptions{Force: true, Context: ctx})
+	if errors.Is(err, docker.ErrNoSuchImage) {
+		return nil
+	}
+	return err
+}
+
+func (r dockerRuntime) RemoveVolume(ctx context.Context, name string) error {
+	err := r.client.RemoveVolumeWithOptions(docker.RemoveVolumeOptions{Name: name, Force: true, Context: ctx})
+	if errors.Is(err, docker.ErrNoSuchVolume) {
+		return nil
+	}
+	return err
+}
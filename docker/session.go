@@ -2,16 +2,18 @@
 package docker
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
 	"golang.org/x/sync/errgroup"
 )
@@ -24,18 +26,103 @@ type Component interface {
 	Start(*Session) error
 }
 
+// ContextComponent is a Component with a context-aware lifecycle.
+// Starting should abort as soon as the context is done, stopping should release any resources the component created.
+type ContextComponent interface {
+	StartContext(context.Context, *Session) error
+	Stop(context.Context, *Session) error
+}
+
+// AdaptComponent turns a Component into a ContextComponent.
+// Components which already implement ContextComponent are returned as is. For all others starting returns
+// when the context is done, while the underlying Start call keeps running in the background, and stopping is a no-op.
+func AdaptComponent(c Component) ContextComponent {
+	if cc, ok := c.(ContextComponent); ok {
+		return cc
+	}
+	return componentAdapter{component: c}
+}
+
+type componentAdapter struct {
+	component Component
+}
+
+func (a componentAdapter) StartContext(ctx context.Context, s *Session) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- a.component.Start(s)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a componentAdapter) Stop(context.Context, *Session) error {
+	return nil
+}
+
 // Session is the docker session, used to manage the lifecycle of components.
 type Session struct {
 	id                         string
 	networkID                  string
+	networksMu                 sync.Mutex
+	networks                   map[string]string
 	inDocker                   bool
+	keepOnFailure              bool
+	ownsNetwork                bool
+	pullPolicy                 PullPolicy
+	imageLock                  *ImageLock
+	imageRewrites              []ImageRewriteRule
+	pulledImages               sync.Map
+	dockerEndpoint             string
+	hostAddress                string
+	clientMu                   sync.Mutex
+	client                     *docker.Client
+	runtime                    Runtime
 	mu                         sync.Mutex
 	serviceAddresses           map[string]string
 	hostMappedServiceAddresses map[string]string
+	aliasServiceAddresses      map[string]string
+	endpoints                  map[string]Endpoint
+	services                   map[string]ServiceInfo
+	startedAt                  time.Time
+	ownerPID                   int
+	bakeVersion                string
+	resources                  []trackedResource
+}
+
+// SessionOptionFunc allows for customization of a Session.
+type SessionOptionFunc func(*Session)
+
+// WithKeepOnFailure keeps the resources created by a failed StartComponents call, which is useful for debugging.
+// It can also be enabled by setting the BAKE_KEEP_ON_FAILURE env var.
+func WithKeepOnFailure() SessionOptionFunc {
+	return func(s *Session) {
+		s.keepOnFailure = true
+	}
+}
+
+// WithDockerEndpoint connects the session to the Docker daemon at the endpoint, e.g. "tcp://10.0.0.2:2376".
+// By default the endpoint of the DOCKER_HOST env var is used, falling back to the local daemon.
+func WithDockerEndpoint(endpoint string) SessionOptionFunc {
+	return func(s *Session) {
+		s.dockerEndpoint = endpoint
+	}
+}
+
+// WithDockerClient makes the session use a pre-configured Docker client, e.g. for a remote daemon with custom TLS.
+func WithDockerClient(client *docker.Client) SessionOptionFunc {
+	return func(s *Session) {
+		s.client = client
+	}
 }
 
 // NewSession prepares a new Docker session.
-func NewSession(id, networkID string) (*Session, error) {
+func NewSession(id, networkID string, opts ...SessionOptionFunc) (*Session, error) {
 	if id == "" {
 		return nil, errors.New("ID is required")
 	}
@@ -44,13 +131,43 @@ func NewSession(id, networkID string) (*Session, error) {
 		return nil, errors.New("networkID is required, bridge network not supported")
 	}
 
-	return &Session{
+	keepOnFailure, _ := strconv.ParseBool(os.Getenv("BAKE_KEEP_ON_FAILURE"))
+	_, ownsNetwork := createdNetworks.Load(networkID)
+	pullPolicy, err := pullPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	imageLock, err := imageLockFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	imageRewrites, err := imageRewritesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
 		id:                         id,
 		networkID:                  networkID,
 		inDocker:                   InDocker(),
+		keepOnFailure:              keepOnFailure,
+		ownsNetwork:                ownsNetwork,
+		pullPolicy:                 pullPolicy,
+		imageLock:                  imageLock,
+		imageRewrites:              imageRewrites,
 		serviceAddresses:           map[string]string{},
 		hostMappedServiceAddresses: map[string]string{},
-	}, nil
+		aliasServiceAddresses:      map[string]string{},
+		startedAt:                  time.Now().UTC(),
+		ownerPID:                   os.Getpid(),
+		bakeVersion:                bakeVersion(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
 }
 
 // ID returns the Session ID.
@@ -68,13 +185,89 @@ func (s *Session) InDocker() bool {
 	return s.inDocker
 }
 
+// DockerClient returns the Docker client of the session, which is shared by all its operations.
+// The client is created on first use, unless one was set with WithDockerClient.
+func (s *Session) DockerClient() (*docker.Client, error) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+
+	return s.dockerClient()
+}
+
+// Runtime returns the container runtime of the session, Docker unless one was set with WithRuntime.
+func (s *Session) Runtime() (Runtime, error) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+
+	if s.runtime != nil {
+		return s.runtime, nil
+	}
+	client, err := s.dockerClient()
+	if err != nil {
+		return nil, err
+	}
+	s.runtime = NewDockerRuntime(client)
+	return s.runtime, nil
+}
+
+func (s *Session) dockerClient() (*docker.Client, error) {
+	if s.client != nil {
+		return s.client, nil
+	}
+	client, err := newDockerClient(s.dockerEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("create docker client: %w", err)
+	}
+	s.client = client
+	return s.client, nil
+}
+
 // StartComponents starts the provided components.
 func (s *Session) StartComponents(cs ...Component) error {
+	ccs := make([]ContextComponent, 0, len(cs))
+	for _, c := range cs {
+		ccs = append(ccs, AdaptComponent(c))
+	}
+	return s.StartComponentsContext(context.Background(), ccs...)
+}
+
+// StartComponentsContext starts the provided components in dependency order.
+// Components implementing DependentComponent start once their dependencies are ready, independent components
+// start in parallel. Cycles or missing dependencies are reported before any component starts, and the images of
+// components implementing ImageLister are pulled up front according to the session pull policy.
+// The first failure cancels the startup of the remaining components and removes every container and image created
+// during this call, along with the session network if bake created it, unless the session keeps them on failure.
+func (s *Session) StartComponentsContext(ctx context.Context, cs ...ContextComponent) error {
+	nodes, err := s.planStartup(cs)
+	if err != nil {
+		return err
+	}
+
+	if err := s.PrefetchImages(ctx, cs...); err != nil {
+		return err
+	}
+
+	checkpoint := s.resourceCheckpoint()
+	err = s.startGraph(ctx, nodes)
+	if err == nil || s.keepOnFailure {
+		return err
+	}
+
+	// The context may be the reason of the failure, the rollback must still go through.
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	if rerr := s.rollback(rctx, checkpoint); rerr != nil {
+		return errors.Join(err, fmt.Errorf("rollback: %w", rerr))
+	}
+	return err
+}
+
+// StopComponents stops the provided components in parallel.
+func (s *Session) StopComponents(ctx context.Context, cs ...ContextComponent) error {
 	g := errgroup.Group{}
 	for _, c := range cs {
-		c := c
 		g.Go(func() error {
-			return c.Start(s)
+			return c.Stop(ctx, s)
 		})
 	}
 	return g.Wait()
@@ -108,6 +301,20 @@ func (s *Session) RegisterHostMappedDockerService(serviceName, endpoint string)
 	return nil
 }
 
+// RegisterAliasDockerService registers an endpoint made of a network alias against the service name.
+func (s *Session) RegisterAliasDockerService(serviceName, endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.aliasServiceAddresses[serviceName]
+	if ok {
+		return fmt.Errorf("service %q which already exists with value: %q", serviceName, v)
+	}
+
+	s.aliasServiceAddresses[serviceName] = endpoint
+	return nil
+}
+
 // DockerToDockerServiceAddress retrieves an internal endpoint for a service name.
 func (s *Session) DockerToDockerServiceAddress(serviceName string) (string, error) {
 	s.mu.Lock()
@@ -134,6 +341,20 @@ func (s *Session) HostToDockerServiceAddress(serviceName string) (string, error)
 	return addr, nil
 }
 
+// AliasServiceAddress retrieves an internal endpoint for a service name made of a network alias, e.g. "kafka:9092".
+// It is stable across sessions, unlike the endpoint of DockerToDockerServiceAddress which includes the session ID.
+func (s *Session) AliasServiceAddress(serviceName string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addr, ok := s.aliasServiceAddresses[serviceName]
+	if !ok {
+		return "", fmt.Errorf("alias service address not registered for %q", serviceName)
+	}
+
+	return addr, nil
+}
+
 // AutoServiceAddress retrieves an endpoint for a service name, appropriate for the running code.
 func (s *Session) AutoServiceAddress(serviceName string) (string, error) {
 	if s.inDocker {
@@ -142,6 +363,14 @@ func (s *Session) AutoServiceAddress(serviceName string) (string, error) {
 	return s.HostToDockerServiceAddress(serviceName)
 }
 
+func (s *Session) hasService(serviceName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.serviceAddresses[serviceName]
+	return ok
+}
+
 // ServiceNames list of registered service names.
 func (s *Session) ServiceNames() []string {
 	serviceNames := make([]string, 0, len(s.serviceAddresses))
@@ -154,10 +383,18 @@ func (s *Session) ServiceNames() []string {
 // PersistToFile serializes a session and writes it to a file.
 func (s *Session) PersistToFile(fpath string) error {
 	b, err := json.MarshalIndent(sessionDump{
+		Version:                    sessionFileVersion,
+		BakeVersion:                s.bakeVersion,
+		StartedAt:                  s.startedAt,
+		OwnerPID:                   s.ownerPID,
 		ID:                         s.id,
 		NetworkID:                  s.networkID,
+		Networks:                   s.namedNetworks(),
 		ServiceAddresses:           s.serviceAddresses,
 		HostMappedServiceAddresses: s.hostMappedServiceAddresses,
+		AliasServiceAddresses:      s.aliasServiceAddresses,
+		Endpoints:                  s.endpoints,
+		Services:                   s.services,
 	}, "", "\t")
 	if err != nil {
 		return err
@@ -178,29 +415,42 @@ func GetEnv() (string, string, error) {
 		sessionID = "000"
 	}
 
-	var err error
 	networkID := os.Getenv("BAKE_NETWORK_ID")
-	if networkID == "" {
-		networkID, err = createNetwork(sessionID)
+	if networkID != "" {
+		return sessionID, networkID, nil
 	}
 
+	client, err := newDockerClient("")
+	if err != nil {
+		return sessionID, "", err
+	}
+	networkID, err = createNetwork(context.Background(), NewDockerRuntime(client), sessionID)
 	return sessionID, networkID, err
 }
 
+// sessionDump is the session file format, fields added after version 1 are omitted when empty.
 type sessionDump struct {
+	Version                    int       `json:",omitempty"`
+	BakeVersion                string    `json:",omitempty"`
+	StartedAt                  time.Time `json:",omitzero"`
+	OwnerPID                   int       `json:",omitempty"`
 	ID                         string
 	NetworkID                  string
+	Networks                   map[string]string `json:",omitempty"`
 	ServiceAddresses           map[string]string
 	HostMappedServiceAddresses map[string]string
+	AliasServiceAddresses      map[string]string      `json:",omitempty"`
+	Endpoints                  map[string]Endpoint    `json:",omitempty"`
+	Services                   map[string]ServiceInfo `json:",omitempty"`
 }
 
 // LoadSession attempts to load a Session from the default file location.
-func LoadSession() (*Session, error) {
-	return LoadSessionFromFile(InDocker(), DefaultSessionFile)
+func LoadSession(opts ...SessionOptionFunc) (*Session, error) {
+	return LoadSessionFromFile(InDocker(), DefaultSessionFile, opts...)
 }
 
 // LoadSessionFromFile attempts to load a session from a file.
-func LoadSessionFromFile(inDocker bool, fpath string) (*Session, error) {
+func LoadSessionFromFile(inDocker bool, fpath string, opts ...SessionOptionFunc) (*Session, error) {
 	data, err := os.ReadFile(path.Clean(fpath))
 	if err != nil {
 		return nil, err
@@ -210,14 +460,50 @@ func LoadSessionFromFile(inDocker bool, fpath string) (*Session, error) {
 	if err := json.Unmarshal(data, &d); err != nil {
 		return nil, err
 	}
+	if err := checkSessionFileVersion(d.Version); err != nil {
+		return nil, fmt.Errorf("load session from %s: %w", fpath, err)
+	}
 
-	return &Session{
+	pullPolicy, err := pullPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	imageLock, err := imageLockFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	imageRewrites, err := imageRewritesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
 		id:                         d.ID,
 		networkID:                  d.NetworkID,
+		networks:                   d.Networks,
 		inDocker:                   inDocker,
+		pullPolicy:                 pullPolicy,
+		imageLock:                  imageLock,
+		imageRewrites:              imageRewrites,
 		serviceAddresses:           d.ServiceAddresses,
 		hostMappedServiceAddresses: d.HostMappedServiceAddresses,
-	}, nil
+		aliasServiceAddresses:      d.AliasServiceAddresses,
+		endpoints:                  d.Endpoints,
+		services:                   d.Services,
+		startedAt:                  d.StartedAt,
+		ownerPID:                   d.OwnerPID,
+		bakeVersion:                d.BakeVersion,
+	}
+	// Session files written before aliases were registered have none.
+	if s.aliasServiceAddresses == nil {
+		s.aliasServiceAddresses = map[string]string{}
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
 }
 
 // CleanupResources finds all session files and prunes Docker resources associated with them.
@@ -239,10 +525,11 @@ func CleanupSessionResourcesFromFile(fname string) error {
 		return err
 	}
 
-	err = CleanupSessionResources(session)
+	report, err := CleanupSessionResourcesContext(context.Background(), session)
 	if err != nil {
 		return err
 	}
+	fmt.Print(report)
 
 	err = os.Remove(fname)
 	if err != nil {
@@ -252,65 +539,129 @@ func CleanupSessionResourcesFromFile(fname string) error {
 	return nil
 }
 
+// CleanupReport lists the Docker resources removed by a cleanup.
+type CleanupReport struct {
+	Containers []string
+	Images     []string
+	Volumes    []string
+	Networks   []string
+}
+
+// String renders the report as a human readable summary.
+func (r CleanupReport) String() string {
+	var b strings.Builder
+	for _, group := range []struct {
+		kind  string
+		names []string
+	}{
+		{kind: "containers", names: r.Containers},
+		{kind: "images", names: r.Images},
+		{kind: "volumes", names: r.Volumes},
+		{kind: "networks", names: r.Networks},
+	} {
+		fmt.Fprintf(&b, "Removed %d %s", len(group.names), group.kind)
+		if len(group.names) > 0 {
+			fmt.Fprintf(&b, ": %s", strings.Join(group.names, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
 // CleanupSessionResources cleans up Docker resources for a Session.
 func CleanupSessionResources(session *Session) error {
-	pool, err := dockertest.NewPool("")
+	_, err := CleanupSessionResourcesContext(context.Background(), session)
+	return err
+}
+
+// CleanupSessionResourcesContext removes the containers, images, volumes and networks labeled with the session ID,
+// along with the session networks and the containers recorded for its services, and reports what it removed.
+func CleanupSessionResourcesContext(ctx context.Context, session *Session) (CleanupReport, error) {
+	var report CleanupReport
+
+	rt, err := session.Runtime()
 	if err != nil {
-		return err
+		return report, err
 	}
+	filter := sessionFilter(session.id)
 
-	containers, err := pool.Client.ListContainers(docker.ListContainersOptions{All: true})
+	containers, err := rt.ListContainers(ctx, filter)
 	if err != nil {
-		return err
+		return report, err
 	}
-
-	for _, c := range containers {
-		for _, name := range c.Names {
-			if strings.HasPrefix(name, "/"+session.id) {
-				fmt.Println("Removing container:", name)
-				err := pool.RemoveContainerByName(name)
-				if err != nil {
-					return err
-				}
-			}
+	recorded, err := session.recordedContainers(ctx, rt, containers)
+	if err != nil {
+		return report, err
+	}
+	for _, c := range append(containers, recorded...) {
+		name := containerName(c)
+		fmt.Println("Removing container:", name)
+		if err := rt.RemoveContainer(ctx, c.ID); err != nil {
+			return report, err
 		}
+		report.Containers = append(report.Containers, name)
 	}
 
-	err = deleteNetwork(session.networkID)
+	images, err := rt.ListImages(ctx, filter)
 	if err != nil {
-		return err
+		return report, err
+	}
+	for _, img := range images {
+		name := img.ID
+		if len(img.RepoTags) > 0 {
+			name = img.RepoTags[0]
+		}
+		fmt.Println("Removing image:", name)
+		if err := rt.RemoveImage(ctx, img.ID); err != nil {
+			return report, err
+		}
+		report.Images = append(report.Images, name)
 	}
 
-	return nil
-}
-
-func deleteNetwork(id string) error {
-	var pool *dockertest.Pool
-	pool, err := dockertest.NewPool("")
+	volumes, err := rt.ListVolumes(ctx, filter)
 	if err != nil {
-		return err
+		return report, err
+	}
+	for _, v := range volumes {
+		fmt.Println("Removing volume:", v.Name)
+		if err := rt.RemoveVolume(ctx, v.Name); err != nil {
+			return report, err
+		}
+		report.Volumes = append(report.Volumes, v.Name)
 	}
 
-	return pool.Client.RemoveNetwork(id)
-}
-
-func createNetwork(id string) (string, error) {
-	var pool *dockertest.Pool
-	pool, err := dockertest.NewPool("")
+	networks, err := rt.ListNetworks(ctx, filter)
 	if err != nil {
-		return "", err
+		return report, err
+	}
+	networkIDs := []string{session.networkID}
+	for _, id := range session.namedNetworks() {
+		networkIDs = append(networkIDs, id)
+	}
+	for _, n := range networks {
+		networkIDs = append(networkIDs, n.ID)
+	}
+	removed := map[string]bool{"": true}
+	for _, id := range networkIDs {
+		if removed[id] {
+			continue
+		}
+		removed[id] = true
+		fmt.Println("Removing network:", id)
+		if err := rt.RemoveNetwork(ctx, id); err != nil {
+			return report, err
+		}
+		report.Networks = append(report.Networks, id)
 	}
 
-	var net *dockertest.Network
-	net, err = pool.CreateNetwork(id)
+	return report, nil
+}
+
+func createNetwork(ctx context.Context, rt Runtime, id string) (string, error) {
+	networkID, err := rt.CreateNetwork(ctx, id, resourceLabels(id, ""))
 	if err != nil {
 		return "", err
 	}
-	return net.Network.ID, nil
-}
-
-// InDocker indicates whether the current process is running inside a Docker container.
-func InDocker() bool {
-	_, staterr := os.Stat("/.dockerenv")
-	return !os.IsNotExist(staterr)
+	createdNetworks.Store(networkID, struct{}{})
+	return networkID, nil
 }
@@ -1,9 +1,13 @@
 package docker
 
 import (
+	"context"
+	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ory/dockertest/v3/docker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -46,7 +50,128 @@ func TestPersistAndLoadingSession(t *testing.T) {
 	loadedSession, err := LoadSessionFromFile(false, DefaultSessionFile)
 	require.NoError(t, err)
 	assert.Equal(t, loadedSession.id, sess.id)
+	require.NoError(t, loadedSession.RegisterAliasDockerService("redis", "redis:6379"))
 
 	err = os.Remove(DefaultSessionFile)
 	require.NoError(t, err)
 }
+
+func TestPersistAndLoadingSessionMetadata(t *testing.T) {
+	sess, err := NewSession("000", "net")
+	require.NoError(t, err)
+	sess.registerServiceInfo("redis", ServiceInfo{
+		Component:   "redis",
+		Container:   "000-redis",
+		ContainerID: "abc",
+		Image:       "redis:7-alpine",
+		Digest:      "sha256:0123",
+	})
+	fpath := filepath.Join(t.TempDir(), DefaultSessionFile)
+	require.NoError(t, sess.PersistToFile(fpath))
+
+	loaded, err := LoadSessionFromFile(false, fpath)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), loaded.OwnerPID())
+	assert.True(t, sess.StartedAt().Equal(loaded.StartedAt()))
+	assert.Equal(t, sess.BakeVersion(), loaded.BakeVersion())
+	info, ok := loaded.ServiceInfo("redis")
+	require.True(t, ok)
+	assert.Equal(t, "000-redis", info.Container)
+	assert.Equal(t, "sha256:0123", info.Digest)
+
+	var d sessionDump
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &d))
+	assert.Equal(t, sessionFileVersion, d.Version)
+}
+
+func TestLoadSessionFileVersions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(t *testing.T, content string) string {
+		fpath := filepath.Join(dir, DefaultSessionFile)
+		require.NoError(t, os.WriteFile(fpath, []byte(content), 0o600))
+		return fpath
+	}
+
+	t.Run("version 1", func(t *testing.T) {
+		fpath := write(t, `{"ID":"000","NetworkID":"net","ServiceAddresses":{"redis":"000-redis:6379"},`+
+			`"HostMappedServiceAddresses":{"redis":"localhost:32768"}}`)
+		loaded, err := LoadSessionFromFile(false, fpath)
+		require.NoError(t, err)
+		addr, err := loaded.AutoServiceAddress("redis")
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:32768", addr)
+		assert.True(t, loaded.StartedAt().IsZero())
+		assert.Zero(t, loaded.OwnerPID())
+		_, ok := loaded.ServiceInfo("redis")
+		assert.False(t, ok)
+	})
+	t.Run("newer version", func(t *testing.T) {
+		fpath := write(t, `{"Version":99,"ID":"000","NetworkID":"net"}`)
+		_, err := LoadSessionFromFile(false, fpath)
+		assert.ErrorContains(t, err, "session file version 99 is not supported, the latest supported version is 2")
+	})
+}
+
+func TestSessionDockerClient(t *testing.T) {
+	t.Run("shared", func(t *testing.T) {
+		sess, err := NewSession("000", "net", WithDockerEndpoint("tcp://10.0.0.2:2375"))
+		require.NoError(t, err)
+
+		client, err := sess.DockerClient()
+		require.NoError(t, err)
+		assert.Equal(t, "tcp://10.0.0.2:2375", client.Endpoint())
+
+		again, err := sess.DockerClient()
+		require.NoError(t, err)
+		assert.Same(t, client, again)
+	})
+	t.Run("injected", func(t *testing.T) {
+		injected, err := docker.NewClient("tcp://10.0.0.3:2375")
+		require.NoError(t, err)
+		sess, err := NewSession("000", "net", WithDockerClient(injected))
+		require.NoError(t, err)
+
+		client, err := sess.DockerClient()
+		require.NoError(t, err)
+		assert.Same(t, injected, client)
+	})
+	t.Run("loaded", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), DefaultSessionFile)
+		sess, err := NewSession("000", "net")
+		require.NoError(t, err)
+		require.NoError(t, sess.PersistToFile(fpath))
+
+		loaded, err := LoadSessionFromFile(false, fpath, WithDockerEndpoint("tcp://10.0.0.2:2375"))
+		require.NoError(t, err)
+		client, err := loaded.DockerClient()
+		require.NoError(t, err)
+		assert.Equal(t, "tcp://10.0.0.2:2375", client.Endpoint())
+	})
+}
+
+type blockingComponent struct {
+	release chan struct{}
+}
+
+func (c blockingComponent) Start(*Session) error {
+	<-c.release
+	return nil
+}
+
+func TestAdaptComponentCancel(t *testing.T) {
+	c := blockingComponent{release: make(chan struct{})}
+	defer close(c.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := AdaptComponent(c).StartContext(ctx, &Session{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAdaptComponentKeepsContextComponent(t *testing.T) {
+	c := &SimpleComponent{Name: "foo"}
+	assert.Same(t, c, AdaptComponent(c))
+}
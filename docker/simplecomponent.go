@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"time"
 
@@ -16,29 +17,87 @@ import (
 )
 
 // BuildOptions contains simple docker build options.
+// Images are tagged with a hash of the build inputs and reused across sessions while the inputs are unchanged.
 type BuildOptions struct {
 	Dockerfile string
 	ContextDir string
 	BuildArgs  []docker.BuildArg
+	// Target selects the stage of a multi-stage Dockerfile.
+	Target string
+	// Platform selects the image platform, e.g. "linux/amd64", empty means the daemon's platform.
+	Platform string
+	// Secrets are exposed to the build with BuildKit, which requires the docker CLI.
+	Secrets []BuildSecret
 }
 
 // RunOptions contains docker container run options.
 type RunOptions struct {
 	Cmd         []string
 	InitExecCmd string
+	Entrypoint  []string
+	User        string
+	WorkingDir  string
+	Hostname    string
+	// ExtraHosts adds entries to /etc/hosts, e.g. "host.docker.internal:host-gateway".
+	ExtraHosts []string
+	CapAdd     []string
+	Ulimits    []docker.ULimit
+	// Platform selects the image platform, e.g. "linux/amd64".
+	Platform string
+	// StopTimeout is the grace period before the container is killed when stopped.
+	StopTimeout time.Duration
+	// MemoryLimit is the memory limit in bytes, zero means unlimited.
+	MemoryLimit int64
+	// CPULimit is the number of CPUs the container may use, e.g. 0.5, zero means unlimited.
+	CPULimit float64
 }
 
 // SimpleContainerConfig defines a Docker container with associated service ports.
 type SimpleContainerConfig struct {
-	Name               string
-	Repository         string
-	Tag                string
-	Env                []string
-	BuildOpts          *BuildOptions
-	ServicePorts       map[string]string
+	Name         string
+	Repository   string
+	Tag          string
+	Env          []string
+	BuildOpts    *BuildOptions
+	ServicePorts map[string]string
+	// Endpoints lists services reachable at ports of other protocols or with URL schemes, e.g. "jaeger.otlp-grpc",
+	// next to ServicePorts. An endpoint overrides the port of the same service in ServicePorts.
+	Endpoints map[string]Endpoint
+	// StaticServicePorts maps services to fixed host ports, published 1 to 1 and taken as is.
 	StaticServicePorts map[string]string
-	ReadyFunc          func(*Session) error
-	RunOpts            *RunOptions
+	// PreassignedHostPorts lists services which need to know their host port before the container starts, such as
+	// Kafka's advertised listener. A free host port is published 1 to 1 and passed to the func along with the session
+	// host address, and the func returns the env the container needs for them. Unlike the other ports these are picked
+	// by bake instead of Docker, so another process may take them before the container starts, in which case another
+	// port is tried up to a few times. Prefer ServicePorts unless the container must know its host port.
+	PreassignedHostPorts map[string]func(host, port string) []string
+	// ReadyFunc is the legacy readiness check, it is not aware of the startup deadline.
+	ReadyFunc func(*Session) error
+	// ReadyContextFunc is a readiness check bound by the container's startup deadline.
+	// When both ReadyFunc and ReadyContextFunc are set, both must succeed.
+	ReadyContextFunc func(context.Context, *Session) error
+	RunOpts          *RunOptions
+	// DependsOn lists component or service names which must be ready before the container's component starts.
+	DependsOn []string
+	// ServiceEnv maps env var names to service names, each env var is set to the service's
+	// Docker to Docker address when the container starts. Referenced services are implicit dependencies.
+	ServiceEnv map[string]string
+	// RegistryAuth holds the credentials for pulling a private image.
+	// The credentials of the Docker config file are used when it is nil.
+	RegistryAuth *docker.AuthConfiguration
+	// HealthCheck makes readiness wait for the container to be healthy before the ready funcs run.
+	HealthCheck *HealthCheck
+	// Mounts lists the volume, bind and tmpfs mounts of the container.
+	Mounts []Mount
+	// Aliases are extra names the container is reachable at on the session network, next to its name.
+	// Unlike the container name they do not include the session ID, so config files can reference e.g. "kafka:9092".
+	Aliases []string
+	// Networks lists the session networks the container joins, created on first use. Empty means the session network
+	// only, named DefaultNetwork, which has to be listed to join it along with others.
+	Networks []string
+	// StartupTimeout bounds pulling, running and waiting for the container to become ready.
+	// Defaults to RetryMaxTimeout.
+	StartupTimeout time.Duration
 }
 
 // SimpleContainerOptionFunc allows for customization of SimpleContainerConfigs.
@@ -51,6 +110,158 @@ func WithTag(tag string) SimpleContainerOptionFunc {
 	}
 }
 
+// WithStartupTimeout sets the startup deadline in a SimpleContainerConfig.
+func WithStartupTimeout(timeout time.Duration) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.StartupTimeout = timeout
+	}
+}
+
+// WithDependsOn adds component or service names which must be ready before the component starts.
+func WithDependsOn(names ...string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.DependsOn = append(c.DependsOn, names...)
+	}
+}
+
+// WithServiceEnv sets an env var to the Docker to Docker address of a service, once the service is ready.
+func WithServiceEnv(envName, serviceName string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		if c.ServiceEnv == nil {
+			c.ServiceEnv = map[string]string{}
+		}
+		c.ServiceEnv[envName] = serviceName
+	}
+}
+
+// WithPreassignedHostPort publishes a service on a host port known before the container starts.
+// The func returns the env the container needs for the host address and port. The port is picked by bake, not Docker,
+// and may collide with other processes, see SimpleContainerConfig.PreassignedHostPorts.
+func WithPreassignedHostPort(serviceName string, envFunc func(host, port string) []string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		if c.PreassignedHostPorts == nil {
+			c.PreassignedHostPorts = map[string]func(string, string) []string{}
+		}
+		c.PreassignedHostPorts[serviceName] = envFunc
+	}
+}
+
+// WithAliases adds network aliases the container is reachable at from the other containers of the session.
+func WithAliases(aliases ...string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.Aliases = append(c.Aliases, aliases...)
+	}
+}
+
+// WithNetworks attaches the container to the named session networks instead of the session network.
+func WithNetworks(names ...string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		c.Networks = append(c.Networks, names...)
+	}
+}
+
+// WithEndpoint adds a named endpoint to a SimpleContainerConfig.
+func WithEndpoint(serviceName string, e Endpoint) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		if c.Endpoints == nil {
+			c.Endpoints = map[string]Endpoint{}
+		}
+		c.Endpoints[serviceName] = e
+	}
+}
+
+// WithCmd overrides the command in a SimpleContainerConfig.
+func WithCmd(cmd ...string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).Cmd = cmd
+	}
+}
+
+// WithEntrypoint overrides the entrypoint in a SimpleContainerConfig.
+func WithEntrypoint(entrypoint ...string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).Entrypoint = entrypoint
+	}
+}
+
+// WithUser sets the user the container runs as in a SimpleContainerConfig.
+func WithUser(user string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).User = user
+	}
+}
+
+// WithWorkingDir sets the working directory in a SimpleContainerConfig.
+func WithWorkingDir(dir string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).WorkingDir = dir
+	}
+}
+
+// WithHostname sets the container hostname in a SimpleContainerConfig.
+func WithHostname(hostname string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).Hostname = hostname
+	}
+}
+
+// WithExtraHosts adds /etc/hosts entries in a SimpleContainerConfig, e.g. "host.docker.internal:host-gateway".
+func WithExtraHosts(hosts ...string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).ExtraHosts = append(runOptions(c).ExtraHosts, hosts...)
+	}
+}
+
+// WithCapAdd adds Linux capabilities in a SimpleContainerConfig.
+func WithCapAdd(caps ...string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).CapAdd = append(runOptions(c).CapAdd, caps...)
+	}
+}
+
+// WithUlimit sets a ulimit in a SimpleContainerConfig, e.g. "nofile".
+func WithUlimit(name string, soft, hard int64) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).Ulimits = append(runOptions(c).Ulimits, docker.ULimit{Name: name, Soft: soft, Hard: hard})
+	}
+}
+
+// WithPlatform sets the image platform in a SimpleContainerConfig, e.g. "linux/amd64".
+func WithPlatform(platform string) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).Platform = platform
+	}
+}
+
+// WithStopTimeout sets the grace period before the container is killed when stopped in a SimpleContainerConfig.
+func WithStopTimeout(timeout time.Duration) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).StopTimeout = timeout
+	}
+}
+
+// WithMemoryLimit sets the memory limit in bytes in a SimpleContainerConfig.
+func WithMemoryLimit(bytes int64) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).MemoryLimit = bytes
+	}
+}
+
+// WithCPULimit sets the number of CPUs the container may use in a SimpleContainerConfig, e.g. 0.5.
+func WithCPULimit(cpus float64) SimpleContainerOptionFunc {
+	return func(c *SimpleContainerConfig) {
+		runOptions(c).CPULimit = cpus
+	}
+}
+
+// runOptions returns the run options of a SimpleContainerConfig, creating them if needed.
+func runOptions(c *SimpleContainerConfig) *RunOptions {
+	if c.RunOpts == nil {
+		c.RunOpts = &RunOptions{}
+	}
+	return c.RunOpts
+}
+
 // SimpleComponent groups together several containers.
 type SimpleComponent struct {
 	Name       string
@@ -59,13 +270,18 @@ type SimpleComponent struct {
 
 // Start all containers sequentially.
 func (c *SimpleComponent) Start(session *Session) error {
+	return c.StartContext(context.Background(), session)
+}
+
+// StartContext starts all containers sequentially, aborting when the context is done.
+func (c *SimpleComponent) StartContext(ctx context.Context, session *Session) error {
 	if len(c.Containers) == 0 {
 		return fmt.Errorf("component %s has no containers to start", c.Name)
 	}
 
 	for _, container := range c.Containers {
 		fmt.Printf("Component %q is starting container %q\n", c.Name, container.Name)
-		err := c.runContainer(session, container)
+		err := c.startContainer(ctx, session, container)
 		if err != nil {
 			return fmt.Errorf("starting component %q: %w", container.Name, err)
 		}
@@ -74,115 +290,256 @@ func (c *SimpleComponent) Start(session *Session) error {
 	return nil
 }
 
-func (c *SimpleComponent) runContainer(session *Session, conf SimpleContainerConfig) error {
-	pool, err := dockertest.NewPool("")
+// ComponentName returns the component's name.
+func (c *SimpleComponent) ComponentName() string {
+	return c.Name
+}
+
+// ServiceNames lists the services of all containers.
+func (c *SimpleComponent) ServiceNames() []string {
+	var names []string
+	for _, container := range c.Containers {
+		names = append(names, serviceNames(container)...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dependencies lists the dependencies of all containers, excluding the ones the component provides itself.
+func (c *SimpleComponent) Dependencies() []string {
+	own := map[string]bool{c.Name: true}
+	for _, serviceName := range c.ServiceNames() {
+		own[serviceName] = true
+	}
+
+	seen := map[string]bool{}
+	var deps []string
+	add := func(name string) {
+		if own[name] || seen[name] {
+			return
+		}
+		seen[name] = true
+		deps = append(deps, name)
+	}
+	for _, container := range c.Containers {
+		for _, name := range container.DependsOn {
+			add(name)
+		}
+		for _, serviceName := range container.ServiceEnv {
+			add(serviceName)
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// Images lists the images of all containers, except the ones built by the component.
+func (c *SimpleComponent) Images() []ImageRef {
+	var images []ImageRef
+	for _, container := range c.Containers {
+		if container.BuildOpts != nil {
+			continue
+		}
+		images = append(images, containerImage(container))
+	}
+	return images
+}
+
+// CheckReady runs the readiness checks of all containers of an already started component.
+func (c *SimpleComponent) CheckReady(ctx context.Context, session *Session) error {
+	for _, container := range c.Containers {
+		if err := waitReady(ctx, session, container); err != nil {
+			return fmt.Errorf("container %q: %w", container.Name, err)
+		}
+	}
+	return nil
+}
+
+// Stop removes all containers of the component in reverse order.
+func (c *SimpleComponent) Stop(ctx context.Context, session *Session) error {
+	rt, err := session.Runtime()
+	if err != nil {
+		return err
+	}
+
+	for i := len(c.Containers) - 1; i >= 0; i-- {
+		name := session.id + "-" + c.Containers[i].Name
+		fmt.Printf("Component %q is removing container %q\n", c.Name, name)
+		if err := rt.RemoveContainer(ctx, name); err != nil {
+			return fmt.Errorf("stopping component %q: %w", c.Containers[i].Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *SimpleComponent) startContainer(ctx context.Context, session *Session, conf SimpleContainerConfig) error {
+	if err := validateEndpoints(conf); err != nil {
+		return err
+	}
+
+	timeout := conf.StartupTimeout
+	if timeout <= 0 {
+		timeout = RetryMaxTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := c.runContainer(ctx, session, conf)
+	if err == nil {
+		return nil
+	}
+
+	rt, rtErr := session.Runtime()
+	if rtErr != nil {
+		return err
+	}
+	image := containerImage(conf).String()
+	if conf.BuildOpts != nil {
+		image = c.Name
+	}
+	d := diagnoseStartup(ctx, rt, c.Name, session.id+"-"+conf.Name, image, err)
+	fmt.Print(d.Report())
+	if dir := os.Getenv("BAKE_LOGS_DIR"); dir != "" {
+		if err := writeStartupDiagnostics(dir, d); err != nil {
+			fmt.Printf("failed to write startup diagnostics: %v\n", err)
+		}
+	}
+	return d
+}
+
+func (c *SimpleComponent) runContainer(ctx context.Context, session *Session, conf SimpleContainerConfig) error {
+	rt, err := session.Runtime()
 	if err != nil {
 		return err
 	}
 
+	var image ImageRef
 	if conf.BuildOpts != nil {
-		err := pool.Client.BuildImage(docker.BuildImageOptions{
-			Name:           c.Name + ":" + session.id,
-			Dockerfile:     conf.BuildOpts.Dockerfile,
-			SuppressOutput: true,
-			OutputStream:   os.Stdout,
-			ErrorStream:    os.Stderr,
-			ContextDir:     conf.BuildOpts.ContextDir,
-			BuildArgs:      conf.BuildOpts.BuildArgs,
-			RmTmpContainer: true,
-		})
+		client, err := session.DockerClient()
+		if err != nil {
+			return err
+		}
+		var built bool
+		image, built, err = buildImage(ctx, client, session, c.Name, conf.BuildOpts)
 		if err != nil {
-			return fmt.Errorf("build image %s: %w", c.Name+":"+session.id, err)
+			return err
+		}
+		if built {
+			session.trackResource(imageResource, image.String())
+		}
+		conf.Repository = image.Repository
+		conf.Tag = image.Tag
+	} else {
+		image, err = session.resolveImage(containerImage(conf))
+		if err != nil {
+			return err
+		}
+		if _, err := session.pullImage(ctx, rt, image); err != nil {
+			return err
 		}
-		conf.Repository = c.Name
-		conf.Tag = session.id
 	}
 
-	fullContainerName := session.id + "-" + conf.Name
-	runOpts := &dockertest.RunOptions{
-		Name:         fullContainerName,
-		NetworkID:    session.networkID,
-		Tag:          conf.Tag,
-		Repository:   conf.Repository,
-		Env:          conf.Env,
-		PortBindings: map[docker.Port][]docker.PortBinding{},
-		ExposedPorts: []string{},
+	env, err := resolveServiceEnv(session, conf)
+	if err != nil {
+		return err
 	}
 
-	if conf.RunOpts != nil {
-		runOpts.Cmd = conf.RunOpts.Cmd
+	fullContainerName := session.id + "-" + conf.Name
+
+	mounts, err := hostMounts(ctx, session, c.Name, conf.Mounts)
+	if err != nil {
+		return err
 	}
 
-	const tcpSuffix = "/tcp"
+	networkIDs, err := session.containerNetworks(ctx, rt, conf.Networks)
+	if err != nil {
+		return err
+	}
 
-	hostPorts := map[string]string{}
-	for serviceName, nativePort := range conf.ServicePorts {
-		runOpts.ExposedPorts = append(runOpts.ExposedPorts, nativePort+tcpSuffix)
+	var (
+		container *docker.Container
+		hostPorts map[string]string
+	)
+	for attempt := 1; ; attempt++ {
+		var runOpts *dockertest.RunOptions
+		runOpts, hostPorts, err = containerRunOptions(session, c.Name, conf, env)
+		if err != nil {
+			return err
+		}
 
-		if !session.inDocker {
-			// staticPort means that we should map this port 1 to 1 on the host,
-			// trusting that the component has obtained a random one.
-			staticPort, ok := conf.StaticServicePorts[serviceName]
-			if ok {
-				runOpts.ExposedPorts = append(runOpts.ExposedPorts, staticPort+tcpSuffix)
-				runOpts.PortBindings[docker.Port(staticPort+tcpSuffix)] = []docker.PortBinding{
-					{HostIP: "0.0.0.0", HostPort: staticPort},
+		publishPorts, _ := strconv.ParseBool(os.Getenv("BAKE_PUBLISH_PORTS"))
+		configure := func(config *docker.Config, hc *docker.HostConfig) {
+			hc.PublishAllPorts = publishPorts
+			hc.Mounts = mounts
+			config.Image = image.Reference()
+			applyRunOptions(conf.RunOpts, config, hc)
+			config.Healthcheck = healthConfig(conf.HealthCheck)
+		}
+		createOpts := containerOptions(runOpts, configure)
+		createOpts.NetworkingConfig.EndpointsConfig = endpointsConfig(networkIDs, networkAliases(conf))
+		container, err = rt.RunContainer(ctx, createOpts)
+		if err != nil && isPortConflict(err) && len(conf.PreassignedHostPorts) > 0 && attempt < maxPortConflictAttempts {
+			fmt.Printf("Host port of %s is already allocated, retrying with another port\n", fullContainerName)
+			if container != nil {
+				if err := rt.RemoveContainer(ctx, container.ID); err != nil {
+					return fmt.Errorf("remove %s: %w", fullContainerName, err)
 				}
-				hostPorts[serviceName] = staticPort
-				continue
-			}
-
-			// by default we obtain a random port to publish for the native port for this service.
-			mappedPort, err := GetFreePort()
-			if err != nil {
-				return fmt.Errorf("can not obtain random free port: %w", err)
-			}
-			runOpts.PortBindings[docker.Port(nativePort+tcpSuffix)] = []docker.PortBinding{
-				{HostIP: "0.0.0.0", HostPort: mappedPort},
 			}
-			hostPorts[serviceName] = mappedPort
+			continue
 		}
+		break
+	}
+	if container != nil {
+		session.trackResource(containerResource, container.ID)
 	}
-
-	publishPorts, _ := strconv.ParseBool(os.Getenv("BAKE_PUBLISH_PORTS"))
-	hcOpts := func(hc *docker.HostConfig) { hc.PublishAllPorts = publishPorts }
-	resource, err := pool.RunWithOptions(runOpts, hcOpts)
 	if err != nil {
 		return fmt.Errorf("run %s: %w", fullContainerName, err)
 	}
 
 	// Update session service registry.
-	for serviceName, port := range conf.ServicePorts {
-		err := session.RegisterInternalDockerService(serviceName, runOpts.Name+":"+port)
+	digest := imageDigest(ctx, rt, image)
+	for serviceName, e := range containerEndpoints(conf) {
+		err := session.RegisterInternalDockerService(serviceName, fullContainerName+":"+e.Port)
 		if err != nil {
 			return fmt.Errorf("register service %s: %w", serviceName, err)
 		}
+		err = session.RegisterAliasDockerService(serviceName, conf.Name+":"+e.Port)
+		if err != nil {
+			return fmt.Errorf("register alias service %s: %w", serviceName, err)
+		}
+		session.RegisterServiceEndpoint(serviceName, e)
+		session.registerServiceInfo(serviceName, ServiceInfo{
+			Component:   c.Name,
+			Container:   fullContainerName,
+			ContainerID: container.ID,
+			Image:       image.Reference(),
+			Digest:      digest,
+		})
 		if !session.inDocker {
 			hport, ok := hostPorts[serviceName]
 			if !ok {
-				return fmt.Errorf("host service port not found for service %s", serviceName)
+				// The host port was assigned by Docker.
+				hport, err = boundHostPort(container, e)
+				if err != nil {
+					return fmt.Errorf("host service port not found for service %s: %w", serviceName, err)
+				}
 			}
-			err := session.RegisterHostMappedDockerService(serviceName, "localhost:"+hport)
+			err := session.RegisterHostMappedDockerService(serviceName, net.JoinHostPort(session.HostAddress(), hport))
 			if err != nil {
 				return fmt.Errorf("register host service %s: %w", serviceName, err)
 			}
 		}
 	}
 
-	if conf.ReadyFunc != nil {
-		err = conf.ReadyFunc(session)
-		if err != nil {
-			return err
-		}
+	if err := waitReadyOrExit(ctx, rt, session, conf, container.ID); err != nil {
+		return err
 	}
 
 	if conf.RunOpts != nil && conf.RunOpts.InitExecCmd != "" {
-		return pool.Retry(func() error {
-			_, err := resource.Exec([]string{"bash", "-c", conf.RunOpts.InitExecCmd},
-				dockertest.ExecOptions{
-					StdOut: bufio.NewWriter(os.Stdout),
-					StdErr: bufio.NewWriter(os.Stdout),
-				})
+		return RetryContext(ctx, func() error {
+			_, err := rt.ExecContainer(ctx, container.ID, []string{"bash", "-c", conf.RunOpts.InitExecCmd},
+				bufio.NewWriter(os.Stdout), bufio.NewWriter(os.Stdout))
 			return err
 		})
 	}
@@ -190,6 +547,104 @@ func (c *SimpleComponent) runContainer(session *Session, conf SimpleContainerCon
 	return nil
 }
 
+func containerImage(conf SimpleContainerConfig) ImageRef {
+	img := ImageRef{Repository: conf.Repository, Tag: conf.Tag, Auth: conf.RegistryAuth}
+	if conf.RunOpts != nil {
+		img.Platform = conf.RunOpts.Platform
+	}
+	return img
+}
+
+// cpuPeriod is the CFS scheduler period CPU limits are expressed in, in microseconds.
+const cpuPeriod = 100000
+
+// applyRunOptions sets the run options dockertest does not support on the container and host config.
+func applyRunOptions(opts *RunOptions, config *docker.Config, hc *docker.HostConfig) {
+	if opts == nil {
+		return
+	}
+	if opts.StopTimeout > 0 {
+		config.StopTimeout = int(opts.StopTimeout.Seconds())
+	}
+	if opts.MemoryLimit > 0 {
+		hc.Memory = opts.MemoryLimit
+	}
+	if opts.CPULimit > 0 {
+		hc.CPUPeriod = cpuPeriod
+		hc.CPUQuota = int64(opts.CPULimit * cpuPeriod)
+	}
+	hc.Ulimits = opts.Ulimits
+}
+
+// waitReady waits for the container to be healthy if it has a healthcheck and runs its readiness checks.
+// The legacy ReadyFunc is abandoned once the context is done.
+func waitReady(ctx context.Context, session *Session, conf SimpleContainerConfig) error {
+	if conf.HealthCheck != nil {
+		rt, err := session.Runtime()
+		if err != nil {
+			return err
+		}
+		if err := waitHealthy(ctx, rt, session.id+"-"+conf.Name); err != nil {
+			return err
+		}
+	}
+
+	if conf.ReadyContextFunc != nil {
+		if err := conf.ReadyContextFunc(ctx, session); err != nil {
+			return err
+		}
+	}
+
+	if conf.ReadyFunc == nil {
+		return nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- conf.ReadyFunc(session)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// networkAliases returns the names a container is reachable at on the session network: its name and its aliases.
+func networkAliases(conf SimpleContainerConfig) []string {
+	return append([]string{conf.Name}, conf.Aliases...)
+}
+
+func serviceNames(conf SimpleContainerConfig) []string {
+	endpoints := containerEndpoints(conf)
+	names := make([]string, 0, len(endpoints))
+	for serviceName := range endpoints {
+		names = append(names, serviceName)
+	}
+	return names
+}
+
+// resolveServiceEnv appends the env vars referencing service addresses to the container's env.
+func resolveServiceEnv(session *Session, conf SimpleContainerConfig) ([]string, error) {
+	envNames := make([]string, 0, len(conf.ServiceEnv))
+	for envName := range conf.ServiceEnv {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+
+	env := append([]string{}, conf.Env...)
+	for _, envName := range envNames {
+		addr, err := session.DockerToDockerServiceAddress(conf.ServiceEnv[envName])
+		if err != nil {
+			return nil, fmt.Errorf("resolve env %s: %w", envName, err)
+		}
+		env = append(env, envName+"="+addr)
+	}
+	return env, nil
+}
+
 // RetryMaxTimeout is the timeout for the default retry func.
 var RetryMaxTimeout = 5 * time.Minute
 
@@ -197,14 +652,54 @@ var RetryMaxTimeout = 5 * time.Minute
 // All built-in components use this func to detect whether a container is alive and ready.
 // User supplied components may use this helper func or provide their own.
 func Retry(op func() error) error {
+	return RetryContext(context.Background(), op)
+}
+
+// RetryContext is an exponential backoff retry helper which stops retrying once the context is done.
+// It is bound by RetryMaxTimeout as well as by the context deadline.
+func RetryContext(ctx context.Context, op func() error) error {
 	bo := backoff.NewExponentialBackOff()
 	bo.MaxInterval = time.Second * 2
 	bo.MaxElapsedTime = RetryMaxTimeout
-	return backoff.Retry(op, bo)
+	bo.Reset()
+
+	for {
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		var permanent *backoff.PermanentError
+		if errors.As(err, &permanent) {
+			return permanent.Err
+		}
+
+		next := bo.NextBackOff()
+		if next == backoff.Stop {
+			return err
+		}
+
+		timer := time.NewTimer(next)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
 }
 
 // GetFreePort tries to find a free port on the current machine.
+//
+// Deprecated: the port may be taken by another process before it is used. Containers get their host ports
+// assigned by Docker, use SimpleContainerConfig.PreassignedHostPorts when a port must be known in advance.
 func GetFreePort() (string, error) {
+	return freeHostPort()
+}
+
+// freeHostPort returns a port free on the current machine at the time of the call, which another process may take
+// before it is used.
+func freeHostPort() (string, error) {
 	listernCfg := net.ListenConfig{
 		// Configure the listener as needed
 	}
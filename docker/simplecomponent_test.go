@@ -0,0 +1,217 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	errNotReady := errors.New("not ready")
+	err := RetryContext(ctx, func() error { return errNotReady })
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, err, errNotReady)
+}
+
+func TestRetryContextSuccess(t *testing.T) {
+	calls := 0
+	err := RetryContext(context.Background(), func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("not ready")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestStartContextNoContainers(t *testing.T) {
+	c := &SimpleComponent{Name: "foo"}
+	err := c.StartContext(context.Background(), &Session{})
+	assert.EqualError(t, err, "component foo has no containers to start")
+}
+
+func TestRunOptionFuncs(t *testing.T) {
+	conf := SimpleContainerConfig{Name: "mongo"}
+	for _, opt := range []SimpleContainerOptionFunc{
+		WithCmd("mongod", "--nojournal"),
+		WithEntrypoint("/entrypoint.sh"),
+		WithUser("999"),
+		WithExtraHosts("host.docker.internal:host-gateway"),
+		WithUlimit("nofile", 1024, 2048),
+		WithPlatform("linux/amd64"),
+		WithStopTimeout(5 * time.Second),
+		WithMemoryLimit(512 << 20),
+		WithCPULimit(0.5),
+	} {
+		opt(&conf)
+	}
+
+	runOpts, _, err := containerRunOptions(&Session{id: "000", inDocker: true}, "mongo", conf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mongod", "--nojournal"}, runOpts.Cmd)
+	assert.Equal(t, []string{"/entrypoint.sh"}, runOpts.Entrypoint)
+	assert.Equal(t, "999", runOpts.User)
+	assert.Equal(t, []string{"host.docker.internal:host-gateway"}, runOpts.ExtraHosts)
+	assert.Equal(t, "linux/amd64", runOpts.Platform)
+
+	var config docker.Config
+	var hc docker.HostConfig
+	applyRunOptions(conf.RunOpts, &config, &hc)
+	assert.Equal(t, 5, config.StopTimeout)
+	assert.Equal(t, int64(512<<20), hc.Memory)
+	assert.Equal(t, int64(100000), hc.CPUPeriod)
+	assert.Equal(t, int64(50000), hc.CPUQuota)
+	assert.Equal(t, []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}}, hc.Ulimits)
+}
+
+func newFakeSession(t *testing.T, rt *FakeRuntime, opts ...SessionOptionFunc) *Session {
+	t.Helper()
+
+	networkID, err := rt.CreateNetwork(context.Background(), "000", resourceLabels("000", ""))
+	require.NoError(t, err)
+	s, err := NewSession("000", networkID, append([]SessionOptionFunc{WithRuntime(rt)}, opts...)...)
+	require.NoError(t, err)
+	s.inDocker = false
+	return s
+}
+
+func newFakeRedis(ready WaitStrategy) *SimpleComponent {
+	return &SimpleComponent{
+		Name: "redis",
+		Containers: []SimpleContainerConfig{{
+			Name:             "redis",
+			Repository:       "redis",
+			Tag:              "7-alpine",
+			Env:              []string{"REDIS_ARGS=--save ''"},
+			ServicePorts:     map[string]string{"redis": "6379"},
+			ReadyContextFunc: ready,
+		}},
+	}
+}
+
+func TestSimpleComponentStartStop(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	c := newFakeRedis(nil)
+
+	require.NoError(t, sess.StartComponentsContext(context.Background(), c))
+
+	addr, err := sess.DockerToDockerServiceAddress("redis")
+	require.NoError(t, err)
+	assert.Equal(t, "000-redis:6379", addr)
+	addr, err = sess.HostToDockerServiceAddress("redis")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:32768", addr)
+
+	container, err := rt.InspectContainer(context.Background(), "000-redis")
+	require.NoError(t, err)
+	assert.Equal(t, "redis:7-alpine", container.Config.Image)
+	assert.Contains(t, container.Config.Env, "REDIS_ARGS=--save ''")
+	assert.Equal(t, "000", container.Config.Labels[LabelSession])
+	assert.Equal(t, "redis", container.Config.Labels[LabelService])
+	assert.Contains(t, container.NetworkSettings.Networks, "000")
+
+	info, ok := sess.ServiceInfo("redis")
+	require.True(t, ok)
+	assert.Equal(t, "redis", info.Component)
+	assert.Equal(t, "000-redis", info.Container)
+	assert.Equal(t, container.ID, info.ContainerID)
+	assert.Equal(t, "redis:7-alpine", info.Image)
+	assert.NotEmpty(t, info.Digest)
+
+	require.NoError(t, sess.StopComponents(context.Background(), c))
+	_, err = rt.InspectContainer(context.Background(), "000-redis")
+	var noSuchContainer *docker.NoSuchContainer
+	assert.ErrorAs(t, err, &noSuchContainer)
+}
+
+func TestSimpleComponentNetworkAliases(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	c := newFakeRedis(nil)
+	WithAliases("cache")(&c.Containers[0])
+
+	require.NoError(t, sess.StartComponentsContext(context.Background(), c))
+
+	addr, err := sess.AliasServiceAddress("redis")
+	require.NoError(t, err)
+	assert.Equal(t, "redis:6379", addr)
+
+	container, err := rt.InspectContainer(context.Background(), "000-redis")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"redis", "cache"}, container.NetworkSettings.Networks["000"].Aliases)
+
+	_, err = sess.AliasServiceAddress("mongo")
+	assert.EqualError(t, err, `alias service address not registered for "mongo"`)
+}
+
+func TestSimpleComponentExitBeforeReady(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt)
+	c := newFakeRedis(func(ctx context.Context, _ *Session) error {
+		if err := rt.WriteLogs("000-redis", "Fatal error, can't open config file\n"); err != nil {
+			return err
+		}
+		if err := rt.ExitContainer("000-redis", 1); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	err := sess.StartComponentsContext(context.Background(), c)
+	var startupErr *StartupError
+	require.ErrorAs(t, err, &startupErr)
+	assert.Equal(t, "000-redis", startupErr.Container)
+	var exited *ContainerExitedError
+	require.ErrorAs(t, err, &exited)
+	assert.Equal(t, 1, exited.ExitCode)
+	assert.Equal(t, "Fatal error, can't open config file", exited.Logs)
+
+	// The failed container is rolled back.
+	containers, err := rt.ListContainers(context.Background(), sessionFilter("000"))
+	require.NoError(t, err)
+	assert.Empty(t, containers)
+}
+
+func TestSimpleComponentOffline(t *testing.T) {
+	rt := NewFakeRuntime()
+	sess := newFakeSession(t, rt, WithPullPolicy(PullNever))
+
+	err := sess.StartComponentsContext(context.Background(), newFakeRedis(nil))
+	require.EqualError(t, err, "offline mode, missing images must be loaded with docker load: redis:7-alpine")
+
+	rt.AddImage("redis:7-alpine")
+	require.NoError(t, sess.StartComponentsContext(context.Background(), newFakeRedis(nil)))
+}
+
+func TestSimpleComponentWaitForExec(t *testing.T) {
+	rt := NewFakeRuntime()
+	calls := 0
+	rt.ExecFunc = func(c *docker.Container, cmd []string, stdout, _ io.Writer) int {
+		calls++
+		assert.Equal(t, "/000-redis", c.Name)
+		assert.Equal(t, []string{"redis-cli", "ping"}, cmd)
+		if calls < 2 {
+			_, _ = io.WriteString(stdout, "LOADING")
+			return 1
+		}
+		return 0
+	}
+	sess := newFakeSession(t, rt)
+
+	err := sess.StartComponentsContext(context.Background(), newFakeRedis(WaitForExec("redis", "redis-cli", "ping")))
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
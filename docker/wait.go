@@ -0,0 +1,237 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// WaitStrategy checks whether a container is ready, retrying until it is or the context is done.
+// Strategies plug into SimpleContainerConfig.ReadyContextFunc.
+type WaitStrategy func(context.Context, *Session) error
+
+// WithTimeout bounds the strategy by its own timeout, on top of the container's startup deadline.
+func (w WaitStrategy) WithTimeout(timeout time.Duration) WaitStrategy {
+	return func(ctx context.Context, session *Session) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return w(ctx, session)
+	}
+}
+
+// HTTPWaitConfig defines the response an HTTP wait strategy expects.
+type HTTPWaitConfig struct {
+	Method     string
+	StatusCode int
+	// BodyMatch, if set, must match the response body.
+	BodyMatch *regexp.Regexp
+}
+
+// HTTPWaitOptionFunc allows for customization of HTTPWaitConfigs.
+type HTTPWaitOptionFunc func(*HTTPWaitConfig)
+
+// WithMethod sets the request method in an HTTPWaitConfig.
+func WithMethod(method string) HTTPWaitOptionFunc {
+	return func(c *HTTPWaitConfig) {
+		c.Method = method
+	}
+}
+
+// WithStatusCode sets the expected status code in an HTTPWaitConfig.
+func WithStatusCode(code int) HTTPWaitOptionFunc {
+	return func(c *HTTPWaitConfig) {
+		c.StatusCode = code
+	}
+}
+
+// WithBodyMatch sets a regular expression the response body must match in an HTTPWaitConfig.
+func WithBodyMatch(re *regexp.Regexp) HTTPWaitOptionFunc {
+	return func(c *HTTPWaitConfig) {
+		c.BodyMatch = re
+	}
+}
+
+// WaitForHTTP waits for a service to respond to an HTTP request on the path, by default a GET returning 200 OK.
+func WaitForHTTP(serviceName, path string, opts ...HTTPWaitOptionFunc) WaitStrategy {
+	conf := HTTPWaitConfig{Method: http.MethodGet, StatusCode: http.StatusOK}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	return func(ctx context.Context, session *Session) error {
+		addr, err := session.AutoServiceAddress(serviceName)
+		if err != nil {
+			return err
+		}
+		scheme := "http"
+		if e, err := session.ServiceEndpoint(serviceName); err == nil && e.Scheme == "https" {
+			scheme = e.Scheme
+		}
+		url := scheme + "://" + addr + path
+
+		return RetryContext(ctx, func() error {
+			req, err := http.NewRequestWithContext(ctx, conf.Method, url, nil)
+			if err != nil {
+				return fmt.Errorf("failed to create request: %w", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != conf.StatusCode {
+				return fmt.Errorf("got status code: %d from %s", resp.StatusCode, url)
+			}
+			if conf.BodyMatch == nil {
+				return nil
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return err
+			}
+			if !conf.BodyMatch.Match(body) {
+				return fmt.Errorf("body from %s does not match %s", url, conf.BodyMatch)
+			}
+			return nil
+		})
+	}
+}
+
+// WaitForTCP waits for a service to accept TCP connections.
+func WaitForTCP(serviceName string) WaitStrategy {
+	return func(ctx context.Context, session *Session) error {
+		addr, err := session.AutoServiceAddress(serviceName)
+		if err != nil {
+			return err
+		}
+
+		var dialer net.Dialer
+		return RetryContext(ctx, func() error {
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+}
+
+// WaitForLog waits for the logs of a session container to match a regular expression.
+// The container name is the one of its SimpleContainerConfig, without the session prefix.
+func WaitForLog(containerName string, re *regexp.Regexp) WaitStrategy {
+	return func(ctx context.Context, session *Session) error {
+		rt, err := session.Runtime()
+		if err != nil {
+			return err
+		}
+		fullContainerName := session.id + "-" + containerName
+
+		return RetryContext(ctx, func() error {
+			var buf bytes.Buffer
+			if err := rt.ContainerLogs(ctx, fullContainerName, &buf, LogOptions{}); err != nil {
+				return err
+			}
+			if !re.Match(buf.Bytes()) {
+				return fmt.Errorf("logs of %s do not match %s", fullContainerName, re)
+			}
+			return nil
+		})
+	}
+}
+
+// WaitForExec waits for a command run inside a session container to exit with code zero.
+// The container name is the one of its SimpleContainerConfig, without the session prefix.
+func WaitForExec(containerName string, cmd ...string) WaitStrategy {
+	return func(ctx context.Context, session *Session) error {
+		rt, err := session.Runtime()
+		if err != nil {
+			return err
+		}
+		fullContainerName := session.id + "-" + containerName
+
+		return RetryContext(ctx, func() error {
+			var out bytes.Buffer
+			code, err := rt.ExecContainer(ctx, fullContainerName, cmd, &out, &out)
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				return fmt.Errorf("%v exited with code %d: %s", cmd, code, bytes.TrimSpace(out.Bytes()))
+			}
+			return nil
+		})
+	}
+}
+
+// WaitForSQL waits for a database service to answer a ping. The driver must be registered by the caller
+// and dsn builds the data source name from the service address.
+func WaitForSQL(serviceName, driverName string, dsn func(addr string) string) WaitStrategy {
+	return func(ctx context.Context, session *Session) error {
+		addr, err := session.AutoServiceAddress(serviceName)
+		if err != nil {
+			return err
+		}
+
+		db, err := sql.Open(driverName, dsn(addr))
+		if err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+
+		return RetryContext(ctx, func() error {
+			return db.PingContext(ctx)
+		})
+	}
+}
+
+// WaitAll waits for all strategies, one after the other.
+func WaitAll(strategies ...WaitStrategy) WaitStrategy {
+	return func(ctx context.Context, session *Session) error {
+		for _, w := range strategies {
+			if err := w(ctx, session); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WaitAny waits for the first of the strategies to succeed, the others are canceled.
+func WaitAny(strategies ...WaitStrategy) WaitStrategy {
+	return func(ctx context.Context, session *Session) error {
+		if len(strategies) == 0 {
+			return nil
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		errCh := make(chan error, len(strategies))
+		for _, w := range strategies {
+			go func() {
+				errCh <- w(ctx, session)
+			}()
+		}
+
+		errs := make([]error, 0, len(strategies))
+		for range strategies {
+			err := <-errCh
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return fmt.Errorf("no wait strategy succeeded: %w", errors.Join(errs...))
+	}
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForHTTP(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != http.MethodPut || r.URL.Path != "/status" || calls < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	session := newTestSession()
+	require.NoError(t, session.RegisterHostMappedDockerService("web", strings.TrimPrefix(srv.URL, "http://")))
+
+	wait := WaitForHTTP("web", "/status", WithMethod(http.MethodPut), WithBodyMatch(regexp.MustCompile(`"ok"`)))
+	require.NoError(t, wait(context.Background(), session))
+	assert.Equal(t, 2, calls)
+}
+
+func TestWaitForHTTPTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	session := newTestSession()
+	require.NoError(t, session.RegisterHostMappedDockerService("web", strings.TrimPrefix(srv.URL, "http://")))
+
+	err := WaitForHTTP("web", "/").WithTimeout(50*time.Millisecond)(context.Background(), session)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "got status code: 503")
+}
+
+func TestWaitForTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+
+	session := newTestSession()
+	require.NoError(t, session.RegisterHostMappedDockerService("tcp", l.Addr().String()))
+
+	require.NoError(t, WaitForTCP("tcp")(context.Background(), session))
+	assert.EqualError(t, WaitForTCP("missing")(context.Background(), session),
+		`external service address not registered for "missing"`)
+}
+
+func TestWaitAllAny(t *testing.T) {
+	errDown := errors.New("down")
+	ok := func(context.Context, *Session) error { return nil }
+	down := func(context.Context, *Session) error { return errDown }
+	blocked := func(ctx context.Context, _ *Session) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	assert.NoError(t, WaitAll(ok, ok)(context.Background(), nil))
+	assert.ErrorIs(t, WaitAll(ok, down)(context.Background(), nil), errDown)
+
+	assert.NoError(t, WaitAny(down, blocked, ok)(context.Background(), nil))
+	err := WaitAny(down, WaitStrategy(blocked).WithTimeout(10*time.Millisecond))(context.Background(), nil)
+	require.ErrorIs(t, err, errDown)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
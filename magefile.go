@@ -3,6 +3,14 @@
 package main
 
 import (
+	bakedocker "github.com/beatlabs/bake/docker"
+	"github.com/beatlabs/bake/docker/component/awsmock"
+	"github.com/beatlabs/bake/docker/component/consul"
+	"github.com/beatlabs/bake/docker/component/jaeger"
+	"github.com/beatlabs/bake/docker/component/kafka"
+	"github.com/beatlabs/bake/docker/component/mockserver"
+	"github.com/beatlabs/bake/docker/component/mongodb"
+	"github.com/beatlabs/bake/docker/component/redis"
 	"github.com/beatlabs/bake/targets/lint/docker"
 	"github.com/beatlabs/bake/targets/test"
 
@@ -21,4 +29,13 @@ import (
 func init() {
 	docker.DockerFiles = []string{"./Dockerfile"}
 	test.CoverExcludePatterns = []string{"doc/", "docker/component/testservice/"}
+	test.ImageLockComponents = []bakedocker.ImageLister{
+		awsmock.NewComponent(),
+		consul.NewComponent(),
+		jaeger.NewComponent(),
+		kafka.New(),
+		mockserver.NewComponent(),
+		mongodb.NewComponent(),
+		redis.NewComponent(),
+	}
 }
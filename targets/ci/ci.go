@@ -2,6 +2,8 @@
 package ci
 
 import (
+	"os"
+
 	gocode "github.com/beatlabs/bake/targets/code/golang"
 	dockerlint "github.com/beatlabs/bake/targets/lint/docker"
 	golint "github.com/beatlabs/bake/targets/lint/golang"
@@ -15,9 +17,15 @@ func CI() error {
 		gocode.Go{}.FmtCheck,
 		dockerlint.Lint{}.Docker,
 		gocode.Go{}.CheckVendor,
+	}
+	// The image lock is only verified once it has been generated with `mage test:lockImages`.
+	if _, err := os.Stat(test.ImageLockFile); err == nil && len(test.ImageLockComponents) > 0 {
+		targets = append(targets, test.Test{}.VerifyImageLock)
+	}
+	targets = append(targets,
 		golint.Lint{}.Go,
 		test.Test{}.CoverAll,
-	}
+	)
 
 	mg.SerialDeps(targets...)
 
@@ -2,7 +2,14 @@
 package test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/beatlabs/bake/docker"
 	"github.com/beatlabs/bake/internal/sh"
@@ -44,6 +51,14 @@ var (
 	CoverExcludePatterns = []string{}
 	// CoverExcludeFile is the coverage file to prune.
 	CoverExcludeFile = "coverage.txt"
+	// GCTTL is the age after which a bake session found on the Docker daemon is considered stale.
+	GCTTL = 24 * time.Hour
+	// GCEndpoint is the Docker daemon collected by the GC targets, it defaults to DOCKER_HOST or the local daemon.
+	GCEndpoint = ""
+	// ImageLockFile is the lock file pinning the images of ImageLockComponents to their digests.
+	ImageLockFile = docker.DefaultImageLockFile
+	// ImageLockComponents are the components whose images are pinned in ImageLockFile.
+	ImageLockComponents []docker.ImageLister
 )
 
 // Test groups together test related tasks.
@@ -111,8 +126,97 @@ func (Test) Cleanup() error {
 	return docker.CleanupResources()
 }
 
+// GC removes Docker resources of bake sessions older than GCTTL, from any checkout or crashed CI job.
+func (Test) GC() error {
+	sh.PrintStartTarget(namespace, "gc")
+
+	return gc(false)
+}
+
+// GCDryRun lists Docker resources of bake sessions older than GCTTL without removing them.
+func (Test) GCDryRun() error {
+	sh.PrintStartTarget(namespace, "gcDryRun")
+
+	return gc(true)
+}
+
+func gc(dryRun bool) error {
+	stale, err := docker.GarbageCollect(context.Background(), docker.GCOptions{TTL: GCTTL, DryRun: dryRun, Endpoint: GCEndpoint})
+	for _, s := range stale {
+		fmt.Println(s)
+	}
+	if err != nil {
+		return err
+	}
+
+	action := "Removed"
+	if dryRun {
+		action = "Found"
+	}
+	fmt.Printf("%s %d stale sessions older than %s\n", action, len(stale), GCTTL)
+	return nil
+}
+
+// LockImages pins the images of ImageLockComponents to their current digests in ImageLockFile.
+func (Test) LockImages() error {
+	sh.PrintStartTarget(namespace, "lockImages")
+
+	if len(ImageLockComponents) == 0 {
+		return errors.New("please set test.ImageLockComponents in your magefile")
+	}
+
+	lock, err := docker.ResolveImageLock(context.Background(), ImageLockComponents...)
+	if err != nil {
+		return err
+	}
+	if err := lock.PersistToFile(ImageLockFile); err != nil {
+		return err
+	}
+	fmt.Printf("Locked %d images in %s\n", len(lock.Images), ImageLockFile)
+	return nil
+}
+
+// VerifyImageLock checks that ImageLockFile pins exactly the images of ImageLockComponents.
+func (Test) VerifyImageLock() error {
+	sh.PrintStartTarget(namespace, "verifyImageLock")
+
+	lock, err := docker.LoadImageLock(ImageLockFile)
+	if err != nil {
+		return err
+	}
+	if err := lock.Verify(ImageLockComponents...); err != nil {
+		fmt.Println("Verify image lock failed, run the below command to fix it:")
+		fmt.Println("mage test:lockImages")
+		return err
+	}
+	return nil
+}
+
 func run(args []string) error {
-	return sh.RunV(goCmd, args...)
+	env, err := imageLockEnv()
+	if err != nil {
+		return err
+	}
+	return sh.RunWithV(env, goCmd, args...)
+}
+
+// imageLockEnv points BAKE_IMAGE_LOCK to ImageLockFile when it exists, so the sessions of the tests run the pinned
+// images from any package directory. A BAKE_IMAGE_LOCK set by the caller is kept.
+func imageLockEnv() (map[string]string, error) {
+	if os.Getenv("BAKE_IMAGE_LOCK") != "" {
+		return nil, nil
+	}
+	fpath, err := filepath.Abs(ImageLockFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(fpath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return map[string]string{"BAKE_IMAGE_LOCK": fpath}, nil
 }
 
 func getBuildTagFlag(buildTags []string) string {
@@ -0,0 +1,32 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageLockEnv(t *testing.T) {
+	t.Setenv("BAKE_IMAGE_LOCK", "")
+	fpath := filepath.Join(t.TempDir(), "bake-images.lock")
+	lockFile := ImageLockFile
+	ImageLockFile = fpath
+	t.Cleanup(func() { ImageLockFile = lockFile })
+
+	env, err := imageLockEnv()
+	require.NoError(t, err)
+	assert.Empty(t, env)
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"images":{}}`), 0o600))
+	env, err = imageLockEnv()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"BAKE_IMAGE_LOCK": fpath}, env)
+
+	t.Setenv("BAKE_IMAGE_LOCK", "custom.lock")
+	env, err = imageLockEnv()
+	require.NoError(t, err)
+	assert.Empty(t, env)
+}